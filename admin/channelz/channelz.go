@@ -0,0 +1,26 @@
+// Package channelz registers grpc-go's channelz service together with the
+// PoolAdmin service, so the channel IDs reported by PoolAdmin.GetPoolStats
+// can be looked up in channelz. Importing this package turns channelz on.
+//
+//	tracker := grpcpool.NewChannelzTracker()
+//	pool, _ := grpcpool.NewPool(func() (*grpc.ClientConn, error) {
+//		return grpc.Dial(target, grpc.WithInsecure(), tracker.DialOption())
+//	}, grpcpool.WithChannelz(tracker))
+//
+//	s := grpc.NewServer()
+//	channelz.Register(s, pool)
+package channelz
+
+import (
+	"github.com/hunyxv/grpcpool"
+	"github.com/hunyxv/grpcpool/admin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/channelz/service"
+)
+
+// Register registers the channelz service and a PoolAdmin service for pools
+// on gs.
+func Register(gs *grpc.Server, pools ...*grpcpool.Pool) *admin.Server {
+	service.RegisterChannelzServiceToServer(gs)
+	return admin.Register(gs, pools...)
+}
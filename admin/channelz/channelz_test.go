@@ -0,0 +1,66 @@
+package channelz_test
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net"
+	"testing"
+
+	"github.com/hunyxv/grpcpool"
+	"github.com/hunyxv/grpcpool/admin"
+	"github.com/hunyxv/grpcpool/admin/channelz"
+	"github.com/hunyxv/grpcpool/grpcpooltest"
+	"google.golang.org/grpc"
+	channelzpb "google.golang.org/grpc/channelz/grpc_channelz_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestRegister(t *testing.T) {
+	backend := grpcpooltest.NewServer()
+	defer backend.Close()
+	tracker := grpcpool.NewChannelzTracker()
+	p, err := grpcpool.NewPool(func() (*grpc.ClientConn, error) {
+		return backend.Dial(tracker.DialOption())
+	}, grpcpool.WithMaxIdle(2), grpcpool.WithChannelz(tracker),
+		grpcpool.WithLogger(log.New(ioutil.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	channelz.Register(gs, p)
+	go gs.Serve(lis)
+	defer gs.Stop()
+	cc, err := grpc.Dial("bufnet", grpc.WithInsecure(),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+
+	ctx := context.Background()
+	stats, err := admin.NewPoolAdminClient(cc).GetPoolStats(ctx, new(admin.GetPoolStatsRequest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[int64]bool)
+	for _, cs := range stats.Conns {
+		if cs.ChannelzId == 0 || seen[cs.ChannelzId] {
+			t.Fatalf("unexpected channelz id in %v", stats.Conns)
+		}
+		seen[cs.ChannelzId] = true
+
+		resp, err := channelzpb.NewChannelzClient(cc).GetChannel(ctx, &channelzpb.GetChannelRequest{ChannelId: cs.ChannelzId})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if target := resp.Channel.Data.Target; target != cs.Target {
+			t.Fatalf("channel %d target = %q, want %q", cs.ChannelzId, target, cs.Target)
+		}
+	}
+}
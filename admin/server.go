@@ -0,0 +1,272 @@
+// Package admin provides the PoolAdmin gRPC service for inspecting and
+// controlling grpcpool.Pool instances of a running process.
+//
+//	s := grpc.NewServer()
+//	admin.Register(s, pool)
+package admin
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/hunyxv/grpcpool"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+//go:generate protoc --go_out=plugins=grpc,paths=source_relative:. admin.proto
+
+var _ PoolAdminServer = (*Server)(nil)
+
+// Server implements PoolAdminServer, pools are identified by Pool.Name.
+type Server struct {
+	mux   sync.RWMutex
+	pools map[string]*grpcpool.Pool
+	names []string // 按注册顺序
+}
+
+// NewServer returns a Server for pools.
+func NewServer(pools ...*grpcpool.Pool) *Server {
+	s := &Server{pools: make(map[string]*grpcpool.Pool)}
+	s.Add(pools...)
+	return s
+}
+
+// Register registers a Server for pools on gs.
+func Register(gs *grpc.Server, pools ...*grpcpool.Pool) *Server {
+	s := NewServer(pools...)
+	RegisterPoolAdminServer(gs, s)
+	return s
+}
+
+// Add adds pools to s, a pool replaces the one with the same name.
+func (s *Server) Add(pools ...*grpcpool.Pool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for _, p := range pools {
+		if _, ok := s.pools[p.Name()]; !ok {
+			s.names = append(s.names, p.Name())
+		}
+		s.pools[p.Name()] = p
+	}
+}
+
+// Remove removes the pool with the given name from s.
+func (s *Server) Remove(name string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.pools[name]; !ok {
+		return
+	}
+	delete(s.pools, name)
+	for i, n := range s.names {
+		if n == name {
+			s.names = append(s.names[:i], s.names[i+1:]...)
+			break
+		}
+	}
+}
+
+// pool 按名称查找连接池，只注册了一个连接池时名称可以为空
+func (s *Server) pool(name string) (*grpcpool.Pool, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	if name == "" && len(s.names) == 1 {
+		name = s.names[0]
+	}
+	p, ok := s.pools[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "pool %q not found", name)
+	}
+	return p, nil
+}
+
+func (s *Server) all() []*grpcpool.Pool {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	pools := make([]*grpcpool.Pool, 0, len(s.names))
+	for _, name := range s.names {
+		pools = append(pools, s.pools[name])
+	}
+	return pools
+}
+
+// ListPools implements PoolAdminServer.
+func (s *Server) ListPools(context.Context, *ListPoolsRequest) (*ListPoolsResponse, error) {
+	resp := new(ListPoolsResponse)
+	for _, p := range s.all() {
+		resp.Pools = append(resp.Pools, poolInfo(p.Stats()))
+	}
+	return resp, nil
+}
+
+// GetPoolStats implements PoolAdminServer.
+func (s *Server) GetPoolStats(_ context.Context, req *GetPoolStatsRequest) (*PoolStats, error) {
+	p, err := s.pool(req.Pool)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := p.Stats()
+	resp := &PoolStats{
+		Pool:        poolInfo(stats),
+		StreamLimit: int32(stats.StreamLimit),
+		Conns:       make([]*ConnStats, 0, len(stats.Conns)),
+	}
+	for _, cs := range stats.Conns {
+		resp.Conns = append(resp.Conns, &ConnStats{
+			Id:         cs.ID,
+			Target:     cs.Target,
+			State:      cs.State.String(),
+			InFlight:   int32(cs.InFlight),
+			Limit:      int32(cs.Limit),
+			Created:    timestamppb.New(cs.Created),
+			LastUsed:   timestamppb.New(cs.LastUsed),
+			ChannelzId: cs.ChannelzID,
+		})
+	}
+	return resp, nil
+}
+
+// DrainConnection implements PoolAdminServer.
+func (s *Server) DrainConnection(_ context.Context, req *ConnectionRequest) (*emptypb.Empty, error) {
+	p, err := s.pool(req.Pool)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Drain(req.Id); err != nil {
+		return nil, toStatus(err)
+	}
+	return new(emptypb.Empty), nil
+}
+
+// EvictConnection implements PoolAdminServer.
+func (s *Server) EvictConnection(_ context.Context, req *ConnectionRequest) (*emptypb.Empty, error) {
+	p, err := s.pool(req.Pool)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Evict(req.Id); err != nil {
+		return nil, toStatus(err)
+	}
+	return new(emptypb.Empty), nil
+}
+
+// Resize implements PoolAdminServer.
+func (s *Server) Resize(_ context.Context, req *ResizeRequest) (*PoolInfo, error) {
+	p, err := s.pool(req.Pool)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := p.Stats()
+	maxIdle, poolSize := stats.MaxIdle, stats.PoolSize
+	if req.MaxIdle != nil {
+		maxIdle = int(req.MaxIdle.Value)
+	}
+	if req.PoolSize != nil {
+		poolSize = int(req.PoolSize.Value)
+	}
+	if err := p.Resize(maxIdle, poolSize); err != nil {
+		return nil, toStatus(err)
+	}
+	return poolInfo(p.Stats()), nil
+}
+
+// StreamEvents implements PoolAdminServer. The response header is sent once
+// the pools are subscribed, the stream ends when every watched pool is closed.
+func (s *Server) StreamEvents(req *StreamEventsRequest, stream PoolAdmin_StreamEventsServer) error {
+	pools := s.all()
+	if req.Pool != "" {
+		p, err := s.pool(req.Pool)
+		if err != nil {
+			return err
+		}
+		pools = []*grpcpool.Pool{p}
+	}
+
+	type poolEvent struct {
+		pool  string
+		event grpcpool.Event
+	}
+	var (
+		wg     sync.WaitGroup
+		events = make(chan poolEvent)
+		done   = make(chan struct{})
+		ctx    = stream.Context()
+	)
+	for _, p := range pools {
+		ch, cancel := p.Subscribe()
+		defer cancel()
+
+		wg.Add(1)
+		go func(name string, ch <-chan grpcpool.Event) {
+			defer wg.Done()
+			for e := range ch {
+				select {
+				case events <- poolEvent{pool: name, event: e}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(p.Name(), ch)
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case pe := <-events:
+			err := stream.Send(&Event{
+				Pool:   pe.pool,
+				Time:   timestamppb.New(pe.event.Time),
+				Type:   pe.event.Type.String(),
+				ConnId: pe.event.ConnID,
+				Reason: pe.event.Reason,
+			})
+			if err != nil {
+				return err
+			}
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+func poolInfo(stats grpcpool.Stats) *PoolInfo {
+	return &PoolInfo{
+		Name:     stats.Name,
+		Closed:   stats.Closed,
+		MaxIdle:  int32(stats.MaxIdle),
+		PoolSize: int32(stats.PoolSize),
+		Conns:    int32(len(stats.Conns)),
+	}
+}
+
+// toStatus 将连接池错误转换为 gRPC 状态
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, grpcpool.ErrConnNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, grpcpool.ErrInvalidSize):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, grpcpool.ErrPoolClosed):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Error(codes.Unknown, err.Error())
+}
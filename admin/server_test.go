@@ -0,0 +1,159 @@
+package admin_test
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hunyxv/grpcpool"
+	"github.com/hunyxv/grpcpool/admin"
+	"github.com/hunyxv/grpcpool/grpcpooltest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// newAdminClient 启动注册了 pools 的 PoolAdmin 服务并返回客户端
+func newAdminClient(t *testing.T, pools ...*grpcpool.Pool) admin.PoolAdminClient {
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	admin.Register(gs, pools...)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	cc, err := grpc.Dial("bufnet", grpc.WithInsecure(),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return admin.NewPoolAdminClient(cc)
+}
+
+func newPool(t *testing.T, s *grpcpooltest.Server, opts ...grpcpool.Option) *grpcpool.Pool {
+	opts = append([]grpcpool.Option{grpcpool.WithLogger(log.New(ioutil.Discard, "", 0))}, opts...)
+	p, err := grpcpool.NewPool(s.Builder(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+	return p
+}
+
+func TestPoolAdmin(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	p := newPool(t, s, grpcpool.WithName("backend"), grpcpool.WithMaxIdle(2), grpcpool.WithCleanIntervalTime(time.Hour))
+	client := newAdminClient(t, p)
+	ctx := context.Background()
+
+	list, err := client.ListPools(ctx, new(admin.ListPoolsRequest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Pools) != 1 || list.Pools[0].Name != "backend" || list.Pools[0].Conns != 2 {
+		t.Fatalf("unexpected pools: %v", list.Pools)
+	}
+
+	stats, err := client.GetPoolStats(ctx, &admin.GetPoolStatsRequest{Pool: "backend"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Conns) != 2 {
+		t.Fatalf("unexpected stats: %v", stats)
+	}
+	if _, err := client.GetPoolStats(ctx, &admin.GetPoolStatsRequest{Pool: "other"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("unknown pool: %v", err)
+	}
+
+	stream, err := client.StreamEvents(ctx, &admin.StreamEventsRequest{Pool: "backend"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 收到 header 时已经订阅
+	if _, err := stream.Header(); err != nil {
+		t.Fatal(err)
+	}
+
+	evicted := stats.Conns[0].Id
+	if _, err := client.EvictConnection(ctx, &admin.ConnectionRequest{Pool: "backend", Id: evicted}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.EvictConnection(ctx, &admin.ConnectionRequest{Pool: "backend", Id: evicted}); status.Code(err) != codes.NotFound {
+		t.Fatalf("evict twice: %v", err)
+	}
+	e, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Type != "conn_closed" || e.ConnId != evicted || e.Reason != "evicted" {
+		t.Fatalf("unexpected event: %v", e)
+	}
+
+	info, err := client.Resize(ctx, &admin.ResizeRequest{Pool: "backend", MaxIdle: wrapperspb.Int32(3)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.MaxIdle != 3 || info.Conns != 3 || int(info.PoolSize) != p.Stats().PoolSize {
+		t.Fatalf("unexpected pool after resize: %v", info)
+	}
+	if _, err := client.Resize(ctx, &admin.ResizeRequest{Pool: "backend", PoolSize: wrapperspb.Int32(1)}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("pool size below max idle: %v", err)
+	}
+}
+
+func TestPoolAdminDrain(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	p := newPool(t, s, grpcpool.WithMaxIdle(1), grpcpool.WithCleanIntervalTime(10*time.Millisecond))
+	client := newAdminClient(t, p)
+	ctx := context.Background()
+
+	lc, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := p.Stats().Conns[0].ID
+	// 只注册了一个连接池时可以省略名称
+	if _, err := client.DrainConnection(ctx, &admin.ConnectionRequest{Id: id}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 排空中的连接不再发放租约
+	lc2, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conns := p.Stats().Conns; len(conns) != 2 || conns[0].InFlight != 1 {
+		t.Fatalf("lease granted on a draining connection: %+v", conns)
+	}
+	p.Put(lc2)
+
+	time.Sleep(50 * time.Millisecond)
+	if p.Stats().Conns[0].ID != id {
+		t.Fatal("draining connection closed with an outstanding lease")
+	}
+
+	p.Put(lc)
+	deadline := time.Now().Add(time.Second)
+	for {
+		var found bool
+		for _, cs := range p.Stats().Conns {
+			found = found || cs.ID == id
+		}
+		if !found {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("drained connection was not closed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,192 @@
+package grpcpool
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	breakerClosed = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// BreakerConfig 单个 grpcConn 的熔断器配置
+type BreakerConfig struct {
+	// Window 统计错误率的时间窗口
+	Window time.Duration
+
+	// MinRequests 窗口内的调用次数达到 MinRequests 后才会熔断
+	MinRequests int
+
+	// ErrorRate 窗口内错误率超过 ErrorRate 时熔断
+	ErrorRate float64
+
+	// Cooldown 熔断后经过 Cooldown 进入半开状态
+	Cooldown time.Duration
+
+	// HalfOpenProbes 半开状态下允许的探测租约数，
+	// 探测调用全部成功后熔断器关闭
+	HalfOpenProbes int
+
+	// FailureCodes 计为失败的状态码，grpc.ErrClientConnClosing 总是计为失败
+	FailureCodes []codes.Code
+}
+
+// DefaultBreakerConfig returns the default circuit breaker config.
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		Window:         10 * time.Second,
+		MinRequests:    20,
+		ErrorRate:      0.5,
+		Cooldown:       5 * time.Second,
+		HalfOpenProbes: 3,
+		FailureCodes: []codes.Code{
+			codes.Unavailable,
+			codes.DeadlineExceeded,
+			codes.ResourceExhausted,
+		},
+	}
+}
+
+type breaker struct {
+	mux   sync.Mutex
+	cfg   *BreakerConfig
+	clock Clock
+
+	state       int
+	windowStart time.Time
+	total       int
+	failures    int
+	openedAt    time.Time // 熔断或进入半开状态的时间
+	probes      int       // 半开状态下已发放的探测租约
+	successes   int       // 半开状态下成功的探测调用
+}
+
+func newBreaker(cfg *BreakerConfig, clock Clock) *breaker {
+	return &breaker{
+		cfg:         cfg,
+		clock:       clock,
+		windowStart: clock.Now(),
+	}
+}
+
+// allow 判断是否允许从该连接获取租约，半开状态下占用一个探测名额时
+// probe 为 true，租约获取失败时需要调用 cancelProbe 归还
+func (b *breaker) allow() (ok, probe bool) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	now := b.clock.Now()
+	switch b.state {
+	case breakerOpen:
+		if now.Sub(b.openedAt) < b.cfg.Cooldown {
+			return false, false
+		}
+		b.state = breakerHalfOpen
+		b.openedAt = now
+		b.probes, b.successes = 0, 0
+	case breakerHalfOpen:
+		// 探测租约没有上报结果（例如直接使用 Get/Put）时，冷却后重新发放
+		if b.probes >= b.cfg.HalfOpenProbes && now.Sub(b.openedAt) >= b.cfg.Cooldown {
+			b.openedAt = now
+			b.probes, b.successes = 0, 0
+		}
+	default:
+		return true, false
+	}
+
+	if b.probes >= b.cfg.HalfOpenProbes {
+		return false, false
+	}
+	b.probes++
+	return true, true
+}
+
+// cancelProbe 归还没有发放出去的探测名额
+func (b *breaker) cancelProbe() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if b.state == breakerHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+// blocked 熔断中且未到冷却时间，或者半开状态下探测名额已经用完，
+// 两种情况下都不会发放租约
+func (b *breaker) blocked() bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	cooling := b.clock.Now().Sub(b.openedAt) < b.cfg.Cooldown
+	switch b.state {
+	case breakerOpen:
+		return cooling
+	case breakerHalfOpen:
+		return b.probes >= b.cfg.HalfOpenProbes && cooling
+	}
+	return false
+}
+
+// onResult 上报调用结果
+func (b *breaker) onResult(err error) {
+	failed := b.isFailure(err)
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	now := b.clock.Now()
+	switch b.state {
+	case breakerHalfOpen:
+		if failed {
+			b.trip(now)
+			return
+		}
+		b.successes++
+		if b.successes >= b.cfg.HalfOpenProbes {
+			b.state = breakerClosed
+			b.windowStart = now
+			b.total, b.failures = 0, 0
+		}
+	case breakerClosed:
+		if now.Sub(b.windowStart) > b.cfg.Window {
+			b.windowStart = now
+			b.total, b.failures = 0, 0
+		}
+		b.total++
+		if failed {
+			b.failures++
+		}
+		if b.total >= b.cfg.MinRequests && float64(b.failures)/float64(b.total) >= b.cfg.ErrorRate {
+			b.trip(now)
+		}
+	}
+}
+
+func (b *breaker) trip(now time.Time) {
+	b.state = breakerOpen
+	b.openedAt = now
+	b.total, b.failures = 0, 0
+}
+
+func (b *breaker) isFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if err == grpc.ErrClientConnClosing {
+		return true
+	}
+
+	code := status.Code(err)
+	for _, c := range b.cfg.FailureCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package grpcpool_test
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/hunyxv/grpcpool"
+	"github.com/hunyxv/grpcpool/grpcpooltest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestBreakerStates(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	clock := grpcpooltest.NewFakeClock(time.Now())
+	p := newPool(t, s,
+		grpcpool.WithClock(clock),
+		grpcpool.WithGrpcPoolSize(1),
+		grpcpool.WithMaxIdle(1),
+		grpcpool.WithCleanIntervalTime(time.Hour),
+		grpcpool.WithClientIdleTimeout(time.Hour),
+		grpcpool.WithCircuitBreaker(grpcpool.BreakerConfig{
+			Window:         10 * time.Second,
+			MinRequests:    2,
+			ErrorRate:      0.5,
+			Cooldown:       5 * time.Second,
+			HalfOpenProbes: 1,
+			FailureCodes:   []codes.Code{codes.Unavailable},
+		}))
+	defer p.Close()
+
+	// closed -> open：错误率达到阈值后熔断
+	s.SetError(codes.Unavailable, 1)
+	for i := 0; i < 2; i++ {
+		if err := invokeEcho(p); status.Code(err) != codes.Unavailable {
+			t.Fatalf("call %d: %v, want Unavailable", i, err)
+		}
+	}
+	if err := invokeEcho(p); err != grpcpool.ErrCircuitOpen {
+		t.Fatalf("call on open breaker: %v, want %v", err, grpcpool.ErrCircuitOpen)
+	}
+
+	// open -> half-open -> open：探测调用失败后重新熔断
+	clock.Advance(5 * time.Second)
+	if err := invokeEcho(p); status.Code(err) != codes.Unavailable {
+		t.Fatalf("probe: %v, want Unavailable", err)
+	}
+	if err := invokeEcho(p); err != grpcpool.ErrCircuitOpen {
+		t.Fatalf("call after failed probe: %v, want %v", err, grpcpool.ErrCircuitOpen)
+	}
+	if calls := s.Calls(); calls != 3 {
+		t.Fatalf("server got %d calls, want 3", calls)
+	}
+
+	// open -> half-open -> closed：探测调用成功后关闭
+	s.SetError(codes.Unavailable, 0)
+	clock.Advance(5 * time.Second)
+	for i := 0; i < 3; i++ {
+		if err := invokeEcho(p); err != nil {
+			t.Fatalf("call %d after recovery: %v", i, err)
+		}
+	}
+}
+
+func TestBreakerHalfOpenDoesNotDial(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	clock := grpcpooltest.NewFakeClock(time.Now())
+	p := newPool(t, s,
+		grpcpool.WithClock(clock),
+		grpcpool.WithGrpcPoolSize(2),
+		grpcpool.WithMaxIdle(1),
+		grpcpool.WithCleanIntervalTime(time.Hour),
+		grpcpool.WithClientIdleTimeout(time.Hour),
+		grpcpool.WithCircuitBreaker(grpcpool.BreakerConfig{
+			Window:         10 * time.Second,
+			MinRequests:    2,
+			ErrorRate:      0.5,
+			Cooldown:       5 * time.Second,
+			HalfOpenProbes: 1,
+			FailureCodes:   []codes.Code{codes.Unavailable},
+		}))
+	defer p.Close()
+
+	s.SetError(codes.Unavailable, 1)
+	for i := 0; i < 2; i++ {
+		invokeEcho(p)
+	}
+	s.SetError(codes.Unavailable, 0)
+	clock.Advance(5 * time.Second)
+
+	// 探测名额用完的半开连接视为熔断，不会为此新建连接
+	lc, err := p.Get()
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	defer p.Put(lc)
+	if _, err := p.Get(); err != grpcpool.ErrCircuitOpen {
+		t.Fatalf("Get without probes left: %v, want %v", err, grpcpool.ErrCircuitOpen)
+	}
+	if dials := s.Dials(); dials != 1 {
+		t.Fatalf("%d dials, want 1", dials)
+	}
+}
+
+func TestSuspectConnEvicted(t *testing.T) {
+	dead := grpcpooltest.NewServer()
+	dead.Close()
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	clock := grpcpooltest.NewFakeClock(time.Now())
+
+	// 第一个连接拨向已关闭的服务端，之后的连接正常
+	var dials int
+	p, err := grpcpool.NewPool(func() (*grpc.ClientConn, error) {
+		dials++
+		if dials == 1 {
+			return grpc.Dial("passthrough:///bufconn", grpc.WithInsecure(), grpc.WithContextDialer(dead.DialContext))
+		}
+		return s.Dial()
+	},
+		grpcpool.WithClock(clock),
+		grpcpool.WithGrpcPoolSize(1),
+		grpcpool.WithMaxIdle(1),
+		grpcpool.WithCleanIntervalTime(time.Second),
+		grpcpool.WithClientIdleTimeout(time.Hour),
+		grpcpool.WithLogger(log.New(ioutil.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	id := p.Stats().Conns[0].ID
+
+	err = p.Invoke(context.Background(), grpcpooltest.EchoMethod, new(emptypb.Empty), new(emptypb.Empty), grpcpool.MarkSuspect())
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("call on broken conn: %v, want Unavailable", err)
+	}
+	if !hasEvent(p, grpcpool.EventConnSuspect, id, "") {
+		t.Fatalf("conn %d not marked suspect: %v", id, p.Events())
+	}
+
+	// 清理时复查连接状态，驱逐并补充连接
+	waitFor(t, func() bool { return clock.Waiters() == 1 })
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return hasEvent(p, grpcpool.EventConnClosed, id, "unhealthy") })
+	waitFor(t, func() bool { return len(p.Stats().Conns) == 1 })
+	if err := invokeEcho(p); err != nil {
+		t.Fatalf("call on replacement conn: %v", err)
+	}
+}
+
+func hasEvent(p *grpcpool.Pool, typ grpcpool.EventType, id int32, reason string) bool {
+	for _, e := range p.Events() {
+		if e.Type == typ && e.ConnID == id && e.Reason == reason {
+			return true
+		}
+	}
+	return false
+}
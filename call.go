@@ -0,0 +1,237 @@
+package grpcpool
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CallOption configures a call made through Pool.Do or Pool.Invoke.
+// It also implements grpc.CallOption, so it can be passed to Pool.Invoke
+// together with ordinary grpc call options.
+type CallOption struct {
+	grpc.EmptyCallOption
+
+	apply func(*callOption)
+}
+
+type callOption struct {
+	// 连接级错误时在其他 grpcConn 上重试的次数，小于 0 时使用 RetryPolicy
+	retries int
+
+	// 调用是否幂等，幂等调用可以按 RetryPolicy.RetryableCodes 重试
+	idempotent bool
+
+	// 连接级错误时将连接标记为可疑
+	markSuspect bool
+
+	// 对冲请求的间隔以及最大尝试次数，见 Hedge
+	hedgeDelay    time.Duration
+	hedgeAttempts int
+
+	// 获取租约的优先级，见 AtPriority
+	priority    Priority
+	hasPriority bool
+}
+
+// Retry returns a CallOption which retries the call up to n times on a
+// different grpcConn, overriding RetryPolicy.MaxAttempts. Whether a failed
+// call is retried is still decided by RetryPolicy and Idempotent.
+func Retry(n int) CallOption {
+	return CallOption{apply: func(co *callOption) {
+		co.retries = n
+	}}
+}
+
+// MarkSuspect returns a CallOption which marks the grpcConn as suspect
+// when the call fails with a connection level error, the connection
+// will be checked and evicted by the cleaner if it is unhealthy.
+func MarkSuspect() CallOption {
+	return CallOption{apply: func(co *callOption) {
+		co.markSuspect = true
+	}}
+}
+
+// Idempotent returns a CallOption which marks the call as idempotent,
+// so it is also retried on RetryPolicy.RetryableCodes.
+func Idempotent() CallOption {
+	return CallOption{apply: func(co *callOption) {
+		co.idempotent = true
+	}}
+}
+
+// AtPriority returns a CallOption which gets the connection at priority pr,
+// like a ctx from NewPriorityContext.
+func AtPriority(pr Priority) CallOption {
+	return CallOption{apply: func(co *callOption) {
+		co.priority = pr
+		co.hasPriority = true
+	}}
+}
+
+// context 为 ctx 附加 AtPriority 设置的优先级
+func (co *callOption) context(ctx context.Context) context.Context {
+	if !co.hasPriority {
+		return ctx
+	}
+	return NewPriorityContext(ctx, co.priority)
+}
+
+func newCallOption(opts []CallOption) *callOption {
+	co := &callOption{retries: -1}
+	for _, o := range opts {
+		if o.apply != nil {
+			o.apply(co)
+		}
+	}
+	return co
+}
+
+// Do gets a connection from the pool, calls fn with it and puts it back
+// on every path, including when fn panics.
+func (p *Pool) Do(ctx context.Context, fn func(grpc.ClientConnInterface) error, opts ...CallOption) error {
+	if err := p.rateWait(ctx, ""); err != nil {
+		return err
+	}
+	return p.do(ctx, newCallOption(opts), fn)
+}
+
+// Invoke performs a unary RPC on a pooled connection, CallOption in opts
+// are applied to the pool call and the others are passed to grpc.
+func (p *Pool) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	var (
+		poolOpts []CallOption
+		grpcOpts = make([]grpc.CallOption, 0, len(opts))
+	)
+	for _, o := range opts {
+		if co, ok := o.(CallOption); ok {
+			poolOpts = append(poolOpts, co)
+			continue
+		}
+		grpcOpts = append(grpcOpts, o)
+	}
+
+	if err := p.rateWait(ctx, method); err != nil {
+		return err
+	}
+
+	co := newCallOption(poolOpts)
+	if co.hedgeAttempts > 1 {
+		msg, ok := reply.(proto.Message)
+		if !ok {
+			return ErrHedgeReply
+		}
+		return p.hedge(ctx, co, method, args, msg, grpcOpts)
+	}
+
+	return p.do(ctx, co, func(cc grpc.ClientConnInterface) error {
+		return cc.Invoke(ctx, method, args, reply, grpcOpts...)
+	})
+}
+
+func (p *Pool) do(ctx context.Context, co *callOption, fn func(grpc.ClientConnInterface) error) error {
+	retries := co.retries
+	if retries < 0 {
+		retries = p.opt.RetryPolicy.MaxAttempts - 1
+	}
+
+	var failed *grpcConn
+	skip := func(gc *grpcConn) bool {
+		return gc == failed
+	}
+
+	getCtx := co.context(ctx)
+	for attempt := 0; ; attempt++ {
+		lc, err := p.get(getCtx, skip, p.opt.Nonblocking)
+		if err != nil {
+			return err
+		}
+
+		gconn := lc.(*logicConn).gconn
+		err = p.call(lc, fn)
+		if err == nil {
+			return nil
+		}
+
+		connFailure := isConnFailure(gconn, err)
+		retry := attempt < retries && p.opt.RetryPolicy.retryable(err, connFailure, co.idempotent)
+		if connFailure && (retry || co.markSuspect) {
+			gconn.markSuspect()
+		}
+		if !retry {
+			return err
+		}
+		failed = gconn
+	}
+}
+
+// call 执行 fn 并上报调用结果，保证连接被归还
+func (p *Pool) call(lc LogicConn, fn func(grpc.ClientConnInterface) error) error {
+	defer p.Put(lc)
+
+	start := p.opt.Clock.Now()
+	err := fn(lc.Conn())
+	lc.(*logicConn).gconn.report(p.opt.Clock.Now().Sub(start), err)
+	return err
+}
+
+// RetryPolicy 连接级错误的重试策略
+type RetryPolicy struct {
+	// MaxAttempts 最大尝试次数（包括第一次调用），小于等于 1 时不重试
+	MaxAttempts int
+
+	// RetryableCodes 幂等调用（见 Idempotent）可以重试的状态码
+	RetryableCodes []codes.Code
+
+	// IdempotentOnly 为 true 时，连接级错误也只重试幂等调用。
+	// 连接断开时请求可能已经送达服务端，默认为 true
+	IdempotentOnly bool
+}
+
+func (rp RetryPolicy) retryable(err error, connFailure, idempotent bool) bool {
+	if !idempotent {
+		return connFailure && !rp.IdempotentOnly
+	}
+	if connFailure {
+		return true
+	}
+
+	code := status.Code(err)
+	for _, c := range rp.RetryableCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// isOverload 判断调用是否因后端过载失败
+func isOverload(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	switch status.Code(err) {
+	case codes.ResourceExhausted, codes.DeadlineExceeded, codes.Unavailable:
+		return true
+	}
+	return false
+}
+
+// isConnFailure 判断是否为连接级别的错误：客户端连接已关闭，或者返回
+// Unavailable 时 grpcConn 已被杀死或者不再 READY。连接仍然 READY 时
+// Unavailable 由服务端返回，不属于连接级错误
+func isConnFailure(gc *grpcConn, err error) bool {
+	if err == grpc.ErrClientConnClosing {
+		return true
+	}
+	if status.Code(err) != codes.Unavailable {
+		return false
+	}
+	return gc.isKilled() || !gc.isReady()
+}
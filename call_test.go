@@ -0,0 +1,87 @@
+package grpcpool_test
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"testing"
+
+	"github.com/hunyxv/grpcpool"
+	"github.com/hunyxv/grpcpool/grpcpooltest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func newPool(t *testing.T, s *grpcpooltest.Server, opts ...grpcpool.Option) *grpcpool.Pool {
+	opts = append([]grpcpool.Option{grpcpool.WithLogger(log.New(ioutil.Discard, "", 0))}, opts...)
+	p, err := grpcpool.NewPool(s.Builder(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func inflight(p *grpcpool.Pool) int {
+	var n int
+	for _, cs := range p.Stats().Conns {
+		n += cs.InFlight
+	}
+	return n
+}
+
+func TestDoReleasesOnPanic(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	p := newPool(t, s)
+	defer p.Close()
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("panic was swallowed")
+			}
+		}()
+		p.Do(context.Background(), func(grpc.ClientConnInterface) error {
+			panic("boom")
+		})
+	}()
+
+	if n := inflight(p); n != 0 {
+		t.Fatalf("%d leases in flight after panic", n)
+	}
+}
+
+func TestDoRetriesUnavailable(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	p := newPool(t, s)
+	defer p.Close()
+
+	s.SetError(codes.Unavailable, 1)
+	err := p.Invoke(context.Background(), grpcpooltest.EchoMethod, new(emptypb.Empty), new(emptypb.Empty),
+		grpcpool.Retry(2), grpcpool.Idempotent())
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("err = %v, want Unavailable", err)
+	}
+	if calls := s.Calls(); calls != 3 {
+		t.Fatalf("server got %d calls, want 3", calls)
+	}
+
+	// 服务端返回的 Unavailable 不是连接级错误，非幂等调用不会重试
+	p.Invoke(context.Background(), grpcpooltest.EchoMethod, new(emptypb.Empty), new(emptypb.Empty), grpcpool.Retry(2))
+	if calls := s.Calls(); calls != 4 {
+		t.Fatalf("server got %d calls, want 4", calls)
+	}
+
+	// 非幂等调用不会因其他状态码重试
+	s.SetError(codes.Internal, 1)
+	p.Invoke(context.Background(), grpcpooltest.EchoMethod, new(emptypb.Empty), new(emptypb.Empty))
+	if calls := s.Calls(); calls != 5 {
+		t.Fatalf("server got %d calls, want 5", calls)
+	}
+	if n := inflight(p); n != 0 {
+		t.Fatalf("%d leases in flight", n)
+	}
+}
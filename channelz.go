@@ -0,0 +1,105 @@
+package grpcpool
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/serviceconfig"
+)
+
+// channelzBalancerName 记录 channelz ID 的负载均衡策略，实际选取交给 pick_first
+const channelzBalancerName = "grpcpool_channelz"
+
+func init() {
+	balancer.Register(channelzBuilder{})
+}
+
+var (
+	// channelzSeq 每次调用 ChannelzTracker.DialOption 生成一个 token
+	channelzSeq int64
+
+	// channelzIDs token 到 channelz channel ID 的映射
+	channelzIDs sync.Map
+)
+
+// ChannelzTracker records the channelz channel ID of every connection dialed
+// by a pool, see WithChannelz. grpc-go doesn't expose the ID of a ClientConn,
+// the tracker learns it through a load balancing policy which delegates to
+// pick_first.
+//
+// The IDs are 0 unless channelz is turned on, e.g. by importing
+// google.golang.org/grpc/channelz/service.
+type ChannelzTracker struct {
+	last int64 // 最近一次生成的 token
+}
+
+// NewChannelzTracker creates a ChannelzTracker.
+func NewChannelzTracker() *ChannelzTracker {
+	return new(ChannelzTracker)
+}
+
+// DialOption returns a grpc.DialOption which reports the channelz ID of the
+// ClientConn to t. The pool's Builder must call it on every dial, it sets the
+// default service config of the ClientConn to use pick_first.
+func (t *ChannelzTracker) DialOption() grpc.DialOption {
+	token := atomic.AddInt64(&channelzSeq, 1)
+	atomic.StoreInt64(&t.last, token)
+	return grpc.WithDefaultServiceConfig(fmt.Sprintf(
+		`{"loadBalancingConfig":[{%q:{"token":%d}}]}`, channelzBalancerName, token))
+}
+
+// take 返回上次调用以来最近生成的 token，没有时返回 0
+func (t *ChannelzTracker) take() int64 {
+	return atomic.SwapInt64(&t.last, 0)
+}
+
+// channelzID token 对应的 channelz ID，负载均衡策略尚未创建或 channelz 未开启时为 0
+func channelzID(token int64) int64 {
+	if v, ok := channelzIDs.Load(token); ok {
+		return v.(int64)
+	}
+	return 0
+}
+
+type channelzConfig struct {
+	serviceconfig.LoadBalancingConfig
+	Token int64 `json:"token"`
+}
+
+type channelzBuilder struct{}
+
+func (channelzBuilder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	return &channelzBalancer{
+		Balancer: balancer.Get(grpc.PickFirstBalancerName).Build(cc, opts),
+		id:       opts.ChannelzParentID,
+	}
+}
+
+func (channelzBuilder) Name() string {
+	return channelzBalancerName
+}
+
+func (channelzBuilder) ParseConfig(js json.RawMessage) (serviceconfig.LoadBalancingConfig, error) {
+	cfg := new(channelzConfig)
+	if err := json.Unmarshal(js, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+type channelzBalancer struct {
+	balancer.Balancer
+	id int64
+}
+
+func (b *channelzBalancer) UpdateClientConnState(s balancer.ClientConnState) error {
+	if cfg, ok := s.BalancerConfig.(*channelzConfig); ok && cfg.Token != 0 {
+		channelzIDs.Store(cfg.Token, b.id)
+	}
+	s.BalancerConfig = nil
+	return b.Balancer.UpdateClientConnState(s)
+}
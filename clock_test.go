@@ -0,0 +1,63 @@
+package grpcpool_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hunyxv/grpcpool"
+	"github.com/hunyxv/grpcpool/grpcpooltest"
+)
+
+func connIDs(p *grpcpool.Pool) map[int32]bool {
+	ids := make(map[int32]bool)
+	for _, cs := range p.Stats().Conns {
+		ids[cs.ID] = true
+	}
+	return ids
+}
+
+// waitFor 等待后台 goroutine 处理假时钟触发的事件
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestIdleTimeoutWithFakeClock(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	clock := grpcpooltest.NewFakeClock(time.Now())
+	p := newPool(t, s,
+		grpcpool.WithClock(clock),
+		grpcpool.WithMaxIdle(2),
+		grpcpool.WithCleanIntervalTime(time.Second),
+		grpcpool.WithClientIdleTimeout(time.Minute),
+	)
+	defer p.Close()
+
+	before := connIDs(p)
+	waitFor(t, func() bool { return clock.Waiters() == 1 })
+
+	// 未超时的连接不会被替换
+	clock.Advance(30 * time.Second)
+	if ids := connIDs(p); !reflect.DeepEqual(ids, before) {
+		t.Fatalf("conns replaced before idle timeout: %v -> %v", before, ids)
+	}
+
+	clock.Advance(time.Minute)
+	waitFor(t, func() bool {
+		ids := connIDs(p)
+		for id := range before {
+			if ids[id] {
+				return false
+			}
+		}
+		return len(ids) == 2
+	})
+}
@@ -0,0 +1,475 @@
+// Command grpcpool-bench load tests a gRPC backend through grpcpool.
+//
+// Every request is an echo of a wrapperspb.BytesValue on -method, the
+// embedded server started with -server answers any method that way.
+//
+//	grpcpool-bench -server -concurrency 200 -rate 50000 -profile sine -duration 1m -out run.json
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hunyxv/grpcpool"
+	"github.com/hunyxv/grpcpool/grpcpooltest"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type config struct {
+	Target      string        `json:"target"`
+	Method      string        `json:"method"`
+	Concurrency int           `json:"concurrency"`
+	Rate        float64       `json:"rate"`
+	Profile     string        `json:"profile"`
+	Period      time.Duration `json:"period"`
+	Steps       int           `json:"steps"`
+	Duration    time.Duration `json:"duration"`
+	Interval    time.Duration `json:"interval"`
+	Timeout     time.Duration `json:"timeout"`
+	Payload     int           `json:"payload"`
+
+	Server        bool          `json:"server"`
+	ServerLatency time.Duration `json:"server_latency"`
+	MaxStreams    uint          `json:"server_max_streams"`
+
+	PoolSize        int           `json:"pool_size"`
+	MaxStreamsConn  int           `json:"max_streams_client"`
+	MaxIdle         int           `json:"max_idle"`
+	CleanInterval   time.Duration `json:"clean_interval"`
+	IdleTimeout     time.Duration `json:"idle_timeout"`
+	Shards          int           `json:"shards"`
+	RetryAttempts   int           `json:"retry_attempts"`
+	Breaker         bool          `json:"breaker"`
+	Adaptive        string        `json:"adaptive"`
+	DiscoverStreams bool          `json:"discover_streams"`
+	StreamCap       int           `json:"stream_cap"`
+	HedgeDelay      time.Duration `json:"hedge_delay"`
+	HedgeAttempts   int           `json:"hedge_attempts"`
+	HedgeBudget     float64       `json:"hedge_budget"`
+	MinReady        int           `json:"min_ready"`
+	MaxInFlight     int           `json:"max_inflight"`
+	Shed            string        `json:"shed"`
+	QueueTimeout    time.Duration `json:"queue_timeout"`
+	PriorityReserve float64       `json:"priority_reserve"`
+	HighPriority    float64       `json:"high_priority"`
+	Tenants         string        `json:"tenants"`
+	Zones           string        `json:"zones"`
+	LocalZone       string        `json:"local_zone"`
+	Spillover       float64       `json:"spillover"`
+	RateLimit       float64       `json:"rate_limit"`
+	RateBurst       int           `json:"rate_burst"`
+	RateReject      bool          `json:"rate_reject"`
+	LeakTracking    bool          `json:"leak_tracking"`
+
+	Metrics string `json:"metrics"`
+	Out     string `json:"-"`
+}
+
+func parseFlags() *config {
+	cfg := new(config)
+	flag.StringVar(&cfg.Target, "target", "", "backend address, required unless -server is set")
+	flag.StringVar(&cfg.Method, "method", "/grpcpool.bench.Echo/Echo", "full method name to call")
+	flag.IntVar(&cfg.Concurrency, "concurrency", 100, "number of concurrent workers")
+	flag.Float64Var(&cfg.Rate, "rate", 0, "peak requests per second, 0 means unlimited")
+	flag.StringVar(&cfg.Profile, "profile", "constant", "rate profile: constant, step, sine or ramp")
+	flag.DurationVar(&cfg.Period, "period", 30*time.Second, "period of the sine profile and length of each step")
+	flag.IntVar(&cfg.Steps, "steps", 5, "number of steps of the step profile")
+	flag.DurationVar(&cfg.Duration, "duration", 30*time.Second, "test duration")
+	flag.DurationVar(&cfg.Interval, "interval", time.Second, "reporting interval")
+	flag.DurationVar(&cfg.Timeout, "timeout", time.Second, "per call timeout")
+	flag.IntVar(&cfg.Payload, "payload", 16, "request payload size in bytes")
+
+	flag.BoolVar(&cfg.Server, "server", false, "start an embedded echo server and target it")
+	flag.DurationVar(&cfg.ServerLatency, "server-latency", 0, "latency added by the embedded server")
+	flag.UintVar(&cfg.MaxStreams, "server-max-streams", 0, "MAX_CONCURRENT_STREAMS of the embedded server")
+
+	flag.IntVar(&cfg.PoolSize, "pool-size", 0, "grpcpool.WithGrpcPoolSize, 0 keeps the default")
+	flag.IntVar(&cfg.MaxStreamsConn, "max-streams", 0, "grpcpool.WithMaxStreamsClient, 0 keeps the default")
+	flag.IntVar(&cfg.MaxIdle, "max-idle", 3, "grpcpool.WithMaxIdle")
+	flag.DurationVar(&cfg.CleanInterval, "clean-interval", 0, "grpcpool.WithCleanIntervalTime, 0 keeps the default")
+	flag.DurationVar(&cfg.IdleTimeout, "idle-timeout", 0, "grpcpool.WithClientIdleTimeout, 0 keeps the default")
+	flag.IntVar(&cfg.Shards, "shards", 0, "grpcpool.WithShards")
+	flag.IntVar(&cfg.RetryAttempts, "retry-attempts", 0, "RetryPolicy.MaxAttempts, 0 keeps the default")
+	flag.BoolVar(&cfg.Breaker, "breaker", false, "grpcpool.WithCircuitBreaker with the default config")
+	flag.StringVar(&cfg.Adaptive, "adaptive", "", "adaptive limiter: aimd or gradient")
+	flag.BoolVar(&cfg.DiscoverStreams, "discover-streams", false, "grpcpool.WithStreamLimitDiscovery")
+	flag.IntVar(&cfg.StreamCap, "stream-cap", 0, "cap of the discovered stream limit")
+	flag.DurationVar(&cfg.HedgeDelay, "hedge-delay", 0, "hedging delay, 0 disables hedging")
+	flag.IntVar(&cfg.HedgeAttempts, "hedge-attempts", 2, "max hedged attempts")
+	flag.Float64Var(&cfg.HedgeBudget, "hedge-budget", 0, "grpcpool.WithHedgingBudget ratio, 0 keeps the default")
+	flag.IntVar(&cfg.MinReady, "min-ready", 0, "grpcpool.WithMinReady")
+	flag.IntVar(&cfg.MaxInFlight, "max-inflight", 0, "grpcpool.WithMaxInFlight, 0 disables it")
+	flag.StringVar(&cfg.Shed, "shed", "reject", "shed mode of -max-inflight: reject, queue or passthrough")
+	flag.DurationVar(&cfg.QueueTimeout, "queue-timeout", 0, "ShedPolicy.QueueTimeout")
+	flag.Float64Var(&cfg.PriorityReserve, "priority-reserve", 0, "grpcpool.WithPriorityReserve, needs -max-inflight or -pool-size")
+	flag.Float64Var(&cfg.HighPriority, "high-priority", 0, "share of the workers calling at grpcpool.PriorityHigh")
+	flag.StringVar(&cfg.Tenants, "tenants", "", "grpcpool.WithTenants as name:guaranteed:max,..., needs -max-inflight or -pool-size, workers are spread over the tenants")
+	flag.StringVar(&cfg.Zones, "zones", "", "comma separated zones assigned to the conns in turn, enables grpcpool.NewTargetPool")
+	flag.StringVar(&cfg.LocalZone, "local-zone", "", "grpcpool.WithLocalZone, requires -zones")
+	flag.Float64Var(&cfg.Spillover, "spillover", 1, "spillover threshold of -local-zone")
+	flag.Float64Var(&cfg.RateLimit, "rate-limit", 0, "grpcpool.WithRateLimit calls per second, 0 disables it")
+	flag.IntVar(&cfg.RateBurst, "rate-burst", 1, "burst of -rate-limit")
+	flag.BoolVar(&cfg.RateReject, "rate-reject", false, "reject the calls over -rate-limit instead of waiting")
+	flag.BoolVar(&cfg.LeakTracking, "leak-tracking", false, "grpcpool.WithLeakTracking")
+
+	flag.StringVar(&cfg.Metrics, "metrics", "", "serve prometheus metrics and the pool debug page on this address, enables grpcpool.WithDebug")
+	flag.StringVar(&cfg.Out, "out", "", "write JSON results to this file")
+	flag.Parse()
+	return cfg
+}
+
+func main() {
+	cfg := parseFlags()
+	if _, err := newProfile(cfg); err != nil {
+		log.Fatal(err)
+	}
+
+	if cfg.Server {
+		addr, stop, err := startServer(cfg)
+		if err != nil {
+			log.Fatalf("start server: %v", err)
+		}
+		defer stop()
+		cfg.Target = addr
+	}
+	if cfg.Target == "" {
+		log.Fatal("-target or -server is required")
+	}
+
+	pool, err := newPool(cfg)
+	if err != nil {
+		log.Fatalf("create pool: %v", err)
+	}
+	defer pool.Close()
+
+	if cfg.Metrics != "" {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", promhttp.Handler())
+			mux.Handle("/debug/grpcpool", grpcpool.DebugHandler(pool))
+			if err := http.ListenAndServe(cfg.Metrics, mux); err != nil {
+				log.Printf("metrics server: %v", err)
+			}
+		}()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Duration)
+	defer cancel()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		cancel()
+	}()
+
+	res := run(ctx, cfg, pool)
+	res.print(os.Stdout)
+	if cfg.Out != "" {
+		if err := res.writeFile(cfg.Out); err != nil {
+			log.Fatalf("write results: %v", err)
+		}
+	}
+}
+
+func newPool(cfg *config) (*grpcpool.Pool, error) {
+	var (
+		opts     []grpcpool.Option
+		dialOpts = []grpc.DialOption{grpc.WithInsecure(), grpc.WithBlock()}
+	)
+	if cfg.PoolSize > 0 {
+		opts = append(opts, grpcpool.WithGrpcPoolSize(cfg.PoolSize))
+	}
+	if cfg.MaxStreamsConn > 0 {
+		opts = append(opts, grpcpool.WithMaxStreamsClient(cfg.MaxStreamsConn))
+	}
+	opts = append(opts, grpcpool.WithMaxIdle(cfg.MaxIdle))
+	if cfg.CleanInterval > 0 {
+		opts = append(opts, grpcpool.WithCleanIntervalTime(cfg.CleanInterval))
+	}
+	if cfg.IdleTimeout > 0 {
+		opts = append(opts, grpcpool.WithClientIdleTimeout(cfg.IdleTimeout))
+	}
+	if cfg.Shards > 1 {
+		opts = append(opts, grpcpool.WithShards(cfg.Shards))
+	}
+	if cfg.RetryAttempts > 0 {
+		opts = append(opts, grpcpool.WithRetryPolicy(grpcpool.RetryPolicy{
+			MaxAttempts:    cfg.RetryAttempts,
+			RetryableCodes: []codes.Code{codes.Unavailable},
+			IdempotentOnly: true,
+		}))
+	}
+	if cfg.Breaker {
+		opts = append(opts, grpcpool.WithCircuitBreaker(grpcpool.DefaultBreakerConfig()))
+	}
+	switch cfg.Adaptive {
+	case "":
+	case "aimd":
+		opts = append(opts, grpcpool.WithAdaptiveLimit(grpcpool.AIMD(grpcpool.AIMDConfig{Timeout: cfg.Timeout})))
+	case "gradient":
+		opts = append(opts, grpcpool.WithAdaptiveLimit(grpcpool.Gradient(grpcpool.GradientConfig{})))
+	default:
+		return nil, fmt.Errorf("unknown adaptive limiter %q", cfg.Adaptive)
+	}
+	var detector *grpcpool.StreamLimitDetector
+	if cfg.DiscoverStreams {
+		detector = grpcpool.NewStreamLimitDetector()
+		opts = append(opts, grpcpool.WithStreamLimitDiscovery(detector, cfg.StreamCap))
+	}
+	if cfg.HedgeBudget > 0 {
+		opts = append(opts, grpcpool.WithHedgingBudget(cfg.HedgeBudget, float64(cfg.HedgeAttempts)))
+	}
+	if cfg.MinReady > 0 {
+		opts = append(opts, grpcpool.WithMinReady(cfg.MinReady))
+	}
+	if cfg.MaxInFlight > 0 {
+		policy := grpcpool.ShedPolicy{QueueTimeout: cfg.QueueTimeout, Priority: grpcpool.PriorityHigh}
+		switch cfg.Shed {
+		case "reject":
+			policy.Mode = grpcpool.ShedReject
+		case "queue":
+			policy.Mode = grpcpool.ShedQueue
+		case "passthrough":
+			policy.Mode = grpcpool.ShedPassthrough
+		default:
+			return nil, fmt.Errorf("unknown shed mode %q", cfg.Shed)
+		}
+		opts = append(opts, grpcpool.WithMaxInFlight(cfg.MaxInFlight, policy))
+	}
+	if cfg.PriorityReserve > 0 {
+		opts = append(opts, grpcpool.WithPriorityReserve(cfg.PriorityReserve))
+	}
+	if cfg.Tenants != "" {
+		tenants, err := parseTenants(cfg.Tenants)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpcpool.WithTenants(tenants...))
+	}
+	if cfg.LocalZone != "" {
+		opts = append(opts, grpcpool.WithLocalZone(cfg.LocalZone, cfg.Spillover))
+	}
+	if cfg.RateLimit > 0 {
+		limit := grpcpool.RateLimit{Rate: cfg.RateLimit, Burst: cfg.RateBurst}
+		if cfg.RateReject {
+			limit.Mode = grpcpool.RateReject
+		}
+		opts = append(opts, grpcpool.WithRateLimit(limit))
+	}
+	if cfg.LeakTracking {
+		opts = append(opts, grpcpool.WithLeakTracking())
+	}
+	if cfg.Metrics != "" {
+		opts = append(opts, grpcpool.WithDebug())
+	}
+	opts = append(opts, grpcpool.WithLogger(log.New(ioutil.Discard, "", 0)))
+
+	builder := func() (*grpc.ClientConn, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if detector != nil {
+			// 每次拨号使用新的 DialOption，按连接记录流数限制
+			return grpc.DialContext(ctx, cfg.Target, append(dialOpts[:len(dialOpts):len(dialOpts)], detector.DialOption())...)
+		}
+		return grpc.DialContext(ctx, cfg.Target, dialOpts...)
+	}
+	if cfg.Zones == "" {
+		return grpcpool.NewPool(builder, opts...)
+	}
+
+	// 所有连接拨向同一个地址，按顺序标记可用区
+	var (
+		zones = strings.Split(cfg.Zones, ",")
+		mux   sync.Mutex
+		next  int
+	)
+	return grpcpool.NewTargetPool(func() (*grpc.ClientConn, grpcpool.Locality, error) {
+		mux.Lock()
+		zone := zones[next%len(zones)]
+		next++
+		mux.Unlock()
+		conn, err := builder()
+		return conn, grpcpool.Locality{Zone: zone}, err
+	}, opts...)
+}
+
+// parseTenants 解析 name:guaranteed:max 格式的租户列表，max 可以省略
+func parseTenants(s string) ([]grpcpool.Tenant, error) {
+	var tenants []grpcpool.Tenant
+	for _, field := range strings.Split(s, ",") {
+		parts := strings.Split(field, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("invalid tenant %q, want name:guaranteed[:max]", field)
+		}
+		t := grpcpool.Tenant{Name: parts[0]}
+		var err error
+		if t.Guaranteed, err = strconv.ParseFloat(parts[1], 64); err != nil {
+			return nil, fmt.Errorf("invalid tenant %q: %v", field, err)
+		}
+		if len(parts) == 3 {
+			if t.Max, err = strconv.ParseFloat(parts[2], 64); err != nil {
+				return nil, fmt.Errorf("invalid tenant %q: %v", field, err)
+			}
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, nil
+}
+
+func run(ctx context.Context, cfg *config, pool *grpcpool.Pool) *result {
+	prof, _ := newProfile(cfg)
+	limiter := rate.NewLimiter(rate.Inf, 1)
+	if cfg.Rate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(prof(0)), cfg.Concurrency)
+	}
+
+	rec := newRecorder(cfg)
+	start := time.Now()
+
+	var tenants []string
+	if cfg.Tenants != "" {
+		for _, field := range strings.Split(cfg.Tenants, ",") {
+			tenants = append(tenants, strings.SplitN(field, ":", 2)[0])
+		}
+	}
+	high := int(cfg.HighPriority * float64(cfg.Concurrency))
+
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Concurrency; i++ {
+		// 前 high 个 worker 使用高优先级，worker 依次分配给各个租户
+		wctx := ctx
+		if i < high {
+			wctx = grpcpool.NewPriorityContext(wctx, grpcpool.PriorityHigh)
+		}
+		if len(tenants) > 0 {
+			wctx = grpcpool.NewTenantContext(wctx, tenants[i%len(tenants)])
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			worker(wctx, cfg, pool, limiter, rec)
+		}()
+	}
+
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+	adjust := time.NewTicker(100 * time.Millisecond)
+	defer adjust.Stop()
+
+	printHeader(os.Stdout)
+	for {
+		select {
+		case <-adjust.C:
+			if cfg.Rate > 0 {
+				limiter.SetLimit(rate.Limit(prof(time.Since(start))))
+			}
+		case <-ticker.C:
+			iv := rec.flush(time.Since(start), float64(limiter.Limit()), pool.Stats())
+			iv.print(os.Stdout)
+		case <-ctx.Done():
+			wg.Wait()
+			rec.flush(time.Since(start), float64(limiter.Limit()), pool.Stats()).print(os.Stdout)
+			return rec.result(time.Since(start))
+		}
+	}
+}
+
+func worker(ctx context.Context, cfg *config, pool *grpcpool.Pool, limiter *rate.Limiter, rec *recorder) {
+	req := wrapperspb.Bytes(make([]byte, cfg.Payload))
+	opts := []grpc.CallOption{}
+	if cfg.HedgeDelay > 0 {
+		opts = append(opts, grpcpool.Hedge(cfg.HedgeDelay, cfg.HedgeAttempts))
+	}
+
+	for {
+		if !wait(ctx, limiter) {
+			return
+		}
+
+		callCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+		start := time.Now()
+		err := pool.Invoke(callCtx, cfg.Method, req, new(wrapperspb.BytesValue), opts...)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		rec.record(time.Since(start), err)
+	}
+}
+
+// maxReserveDelay 超过该延迟的令牌预约会被取消重试，
+// 避免低 QPS 时的预约在 profile 提高速率后仍然长时间等待
+const maxReserveDelay = 100 * time.Millisecond
+
+// wait 等待限速器放行，ctx 结束时返回 false
+func wait(ctx context.Context, limiter *rate.Limiter) bool {
+	for {
+		r := limiter.Reserve()
+		delay := r.Delay()
+		if delay > maxReserveDelay {
+			r.Cancel()
+			delay = maxReserveDelay
+		} else if delay == 0 {
+			return true
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+			if delay < maxReserveDelay {
+				return true
+			}
+		case <-ctx.Done():
+			timer.Stop()
+			r.Cancel()
+			return false
+		}
+	}
+}
+
+func startServer(cfg *config) (string, func(), error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, err
+	}
+
+	handler := grpcpooltest.EchoHandler
+	if cfg.ServerLatency > 0 {
+		handler = func(srv interface{}, stream grpc.ServerStream) error {
+			time.Sleep(cfg.ServerLatency)
+			return grpcpooltest.EchoHandler(srv, stream)
+		}
+	}
+
+	sopts := []grpc.ServerOption{grpc.UnknownServiceHandler(handler)}
+	if cfg.MaxStreams > 0 {
+		sopts = append(sopts, grpc.MaxConcurrentStreams(uint32(cfg.MaxStreams)))
+	}
+	srv := grpc.NewServer(sopts...)
+	go srv.Serve(lis)
+	return lis.Addr().String(), srv.Stop, nil
+}
+
+func (r *result) writeFile(name string) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, b, 0644)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// profile 返回测试开始 elapsed 之后的目标 QPS
+type profile func(elapsed time.Duration) float64
+
+func newProfile(cfg *config) (profile, error) {
+	peak := cfg.Rate
+	switch cfg.Profile {
+	case "constant":
+		return func(time.Duration) float64 {
+			return peak
+		}, nil
+	case "step":
+		// 每个 period 增加一级，共 steps 级，最后一级为 peak
+		steps := cfg.Steps
+		if steps <= 0 {
+			steps = 1
+		}
+		return func(elapsed time.Duration) float64 {
+			step := int(elapsed/cfg.Period) + 1
+			if step > steps {
+				step = steps
+			}
+			return peak * float64(step) / float64(steps)
+		}, nil
+	case "sine":
+		// 在 [0, peak] 之间按正弦波变化，周期为 period
+		return func(elapsed time.Duration) float64 {
+			phase := 2 * math.Pi * float64(elapsed) / float64(cfg.Period)
+			return peak * (1 - math.Cos(phase)) / 2
+		}, nil
+	case "ramp":
+		// 在整个测试期间从 0 线性增加到 peak
+		return func(elapsed time.Duration) float64 {
+			return peak * math.Min(1, float64(elapsed)/float64(cfg.Duration))
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown rate profile %q", cfg.Profile)
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hunyxv/grpcpool"
+	"google.golang.org/grpc/status"
+)
+
+// histogram 按指数分桶的延迟直方图，相对误差约 2%
+type histogram struct {
+	counts map[int]int64
+	total  int64
+	max    time.Duration
+}
+
+const histogramGrowth = 1.02
+
+func newHistogram() *histogram {
+	return &histogram{counts: make(map[int]int64)}
+}
+
+func (h *histogram) record(d time.Duration) {
+	us := float64(d) / float64(time.Microsecond)
+	bucket := 0
+	if us > 1 {
+		bucket = int(math.Log(us) / math.Log(histogramGrowth))
+	}
+	h.counts[bucket]++
+	h.total++
+	if d > h.max {
+		h.max = d
+	}
+}
+
+func (h *histogram) merge(o *histogram) {
+	for b, n := range o.counts {
+		h.counts[b] += n
+	}
+	h.total += o.total
+	if o.max > h.max {
+		h.max = o.max
+	}
+}
+
+func (h *histogram) percentile(p float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+
+	buckets := make([]int, 0, len(h.counts))
+	for b := range h.counts {
+		buckets = append(buckets, b)
+	}
+	sort.Ints(buckets)
+
+	rank := int64(math.Ceil(p / 100 * float64(h.total)))
+	var seen int64
+	for _, b := range buckets {
+		seen += h.counts[b]
+		if seen >= rank {
+			d := time.Duration(math.Pow(histogramGrowth, float64(b+1)) * float64(time.Microsecond))
+			if d > h.max {
+				d = h.max
+			}
+			return d
+		}
+	}
+	return h.max
+}
+
+// latencies 延迟分位数
+type latencies struct {
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+func (h *histogram) latencies() latencies {
+	return latencies{
+		P50: h.percentile(50),
+		P90: h.percentile(90),
+		P99: h.percentile(99),
+		Max: h.max,
+	}
+}
+
+// interval 一个统计周期的结果
+type interval struct {
+	Elapsed    time.Duration  `json:"elapsed"`
+	TargetRate float64        `json:"target_rate"`
+	Throughput float64        `json:"throughput"`
+	Requests   int64          `json:"requests"`
+	Errors     map[string]int `json:"errors,omitempty"`
+	Latency    latencies      `json:"latency"`
+	Conns      int            `json:"conns"`
+	InFlight   int            `json:"in_flight"`
+	Capacity   int            `json:"capacity"`
+}
+
+type result struct {
+	Config     *config        `json:"config"`
+	Elapsed    time.Duration  `json:"elapsed"`
+	Requests   int64          `json:"requests"`
+	Throughput float64        `json:"throughput"`
+	Errors     map[string]int `json:"errors,omitempty"`
+	Latency    latencies      `json:"latency"`
+	Intervals  []interval     `json:"intervals"`
+}
+
+type recorder struct {
+	mux sync.Mutex
+	cfg *config
+
+	cur    *histogram
+	errors map[string]int
+
+	total       *histogram
+	totalErrors map[string]int
+	intervals   []interval
+	last        time.Duration
+}
+
+func newRecorder(cfg *config) *recorder {
+	return &recorder{
+		cfg:         cfg,
+		cur:         newHistogram(),
+		errors:      make(map[string]int),
+		total:       newHistogram(),
+		totalErrors: make(map[string]int),
+	}
+}
+
+func (r *recorder) record(d time.Duration, err error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.cur.record(d)
+	if err != nil {
+		r.errors[errorName(err)]++
+	}
+}
+
+// poolErrors 连接池自身的错误，不是 gRPC 状态，按名称分别统计
+var poolErrors = []struct {
+	err  error
+	name string
+}{
+	{grpcpool.ErrPoolOverload, "PoolOverload"},
+	{grpcpool.ErrCircuitOpen, "CircuitOpen"},
+	{grpcpool.ErrRateLimited, "RateLimited"},
+	{grpcpool.ErrPoolClosed, "PoolClosed"},
+	{grpcpool.ErrConnClosed, "ConnClosed"},
+	{grpcpool.ErrNotReady, "NotReady"},
+	{grpcpool.ErrHedgeReply, "HedgeReply"},
+}
+
+// errorName 返回 err 的统计分类，连接池错误按名称，其他按 gRPC 状态码
+func errorName(err error) string {
+	for _, e := range poolErrors {
+		if errors.Is(err, e.err) {
+			return e.name
+		}
+	}
+	return status.Code(err).String()
+}
+
+// flush 结束当前统计周期
+func (r *recorder) flush(elapsed time.Duration, targetRate float64, stats grpcpool.Stats) interval {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	iv := interval{
+		Elapsed:  elapsed,
+		Requests: r.cur.total,
+		Latency:  r.cur.latencies(),
+		Conns:    len(stats.Conns),
+	}
+	if r.cfg.Rate > 0 {
+		iv.TargetRate = targetRate
+	}
+	if d := elapsed - r.last; d > 0 {
+		iv.Throughput = float64(r.cur.total) / d.Seconds()
+	}
+	if len(r.errors) > 0 {
+		iv.Errors = r.errors
+	}
+	for _, cs := range stats.Conns {
+		iv.InFlight += cs.InFlight
+		iv.Capacity += cs.Limit
+	}
+
+	for code, n := range r.errors {
+		r.totalErrors[code] += n
+	}
+	r.total.merge(r.cur)
+	r.intervals = append(r.intervals, iv)
+	r.cur = newHistogram()
+	r.errors = make(map[string]int)
+	r.last = elapsed
+	return iv
+}
+
+func (r *recorder) result(elapsed time.Duration) *result {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	res := &result{
+		Config:    r.cfg,
+		Elapsed:   elapsed,
+		Requests:  r.total.total,
+		Latency:   r.total.latencies(),
+		Intervals: r.intervals,
+	}
+	if elapsed > 0 {
+		res.Throughput = float64(r.total.total) / elapsed.Seconds()
+	}
+	if len(r.totalErrors) > 0 {
+		res.Errors = r.totalErrors
+	}
+	return res
+}
+
+func printHeader(w io.Writer) {
+	fmt.Fprintf(w, "%8s %10s %10s %9s %9s %9s %9s %6s %9s  %s\n",
+		"elapsed", "target", "qps", "p50", "p90", "p99", "max", "conns", "inflight", "errors")
+}
+
+func (iv interval) print(w io.Writer) {
+	fmt.Fprintf(w, "%8s %10.0f %10.0f %9s %9s %9s %9s %6d %4d/%-4d  %v\n",
+		iv.Elapsed.Round(time.Second), iv.TargetRate, iv.Throughput,
+		round(iv.Latency.P50), round(iv.Latency.P90), round(iv.Latency.P99), round(iv.Latency.Max),
+		iv.Conns, iv.InFlight, iv.Capacity, iv.Errors)
+}
+
+func (r *result) print(w io.Writer) {
+	fmt.Fprintf(w, "\nrequests: %d in %s, %.0f req/s\n", r.Requests, r.Elapsed.Round(time.Millisecond), r.Throughput)
+	fmt.Fprintf(w, "latency: p50 %s, p90 %s, p99 %s, max %s\n",
+		round(r.Latency.P50), round(r.Latency.P90), round(r.Latency.P99), round(r.Latency.Max))
+	if len(r.Errors) == 0 {
+		return
+	}
+
+	codes := make([]string, 0, len(r.Errors))
+	for code := range r.Errors {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	fmt.Fprintln(w, "errors:")
+	for _, code := range codes {
+		fmt.Fprintf(w, "  %-20s %d\n", code, r.Errors[code])
+	}
+}
+
+func round(d time.Duration) time.Duration {
+	switch {
+	case d > time.Second:
+		return d.Round(time.Millisecond)
+	case d > time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	}
+	return d.Round(time.Microsecond)
+}
@@ -1,13 +1,12 @@
 package grpcpool
 
 import (
-	"fmt"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/hunyxv/grpcpool/internal"
-
+	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/connectivity"
 )
@@ -25,104 +24,332 @@ var _ LogicConn = (*logicConn)(nil)
 type logicConn struct {
 	grpc.ClientConnInterface
 
-	gconn *grpcConn
+	gconn    *grpcConn
+	admitted int64        // 获取租约的时间（UnixNano），仅在开启 WithMaxInFlight 时记录
+	tenant   *tenantState // 租约所属的租户，见 WithTenants
 }
 
-func (lc logicConn) Conn() grpc.ClientConnInterface {
+func (lc *logicConn) Conn() grpc.ClientConnInterface {
 	return lc
 }
 
-func (logicConn) t() {}
+func (*logicConn) t() {}
 
 var logicConnPool = sync.Pool{
-	New: func() interface{} { return logicConn{} },
+	New: func() interface{} { return new(logicConn) },
 }
 
 type grpcConn struct {
+	ts      int64 // 最近一次获取租约的时间（UnixNano），放在首位保证 64 位对齐
+	created int64 // 创建时间（UnixNano）
+
 	p    *Pool
 	conn *grpc.ClientConn
+	cc   grpc.ClientConnInterface // 租约使用的连接，启用故障注入时为 faultConn
 
 	id                int32
-	maxStreamsClient  int
+	maxStreamsClient  int32 // 当前容量，启用 Limiter 时动态调整
 	clientIdleTimeout time.Duration
 	current           int32 // 当前剩余可用
-	lock              sync.Locker
-	ts                time.Time
+	leases            int32 // 当前发放出去的租约数，与容量无关，setCapacity 不会改变它
+	suspect           int32 // 调用失败后标记，由 cleanPeriodically 复查连接状态
+	breaker           *breaker
+	limiter           Limiter
+	limitMux          sync.Mutex    // 串行调整容量
+	resizable         bool          // 容量是否会动态调整
+	closed            int32         // 连接关闭后置 1，get 不再调用 conn.GetState()
+	draining          int32         // 排空中置 1，不再发放租约，租约全部归还后关闭
+	drained           chan struct{} // 排空完成后关闭
+	drainedOnce       sync.Once
+	gauge             prometheus.Gauge // Debug 模式下预先解析的指标
+	channelzToken     int64            // 见 ChannelzTracker，未开启时为 0
+	locality          Locality         // 见 TargetBuilder
+	streams           *connStreamLimit // 服务端通告的流数限制，未开启或无法对应到连接时为 nil
 }
 
-func newGrpcConn(p *Pool, conn *grpc.ClientConn) *grpcConn {
-	return &grpcConn{
+func newGrpcConn(p *Pool, conn *grpc.ClientConn, streams *connStreamLimit) *grpcConn {
+	gc := &grpcConn{
 		id:                atomic.AddInt32(&id, 1),
 		p:                 p,
 		conn:              conn,
-		maxStreamsClient:  p.opt.MaxStreamsClient,
 		clientIdleTimeout: p.opt.ClientIdleTimeout,
-		current:           int32(p.opt.MaxStreamsClient),
-		lock:              internal.NewSpinLock(),
-		ts:                time.Now(),
+		resizable:         p.opt.Limiter != nil || p.opt.StreamLimit != nil,
+		ts:                p.opt.Clock.Now().UnixNano(),
+		drained:           make(chan struct{}),
+		streams:           streams,
+	}
+	gc.maxStreamsClient = int32(gc.streamLimit())
+	gc.current = gc.maxStreamsClient
+	gc.created = gc.ts
+	gc.cc = conn
+	if p.opt.FaultInjector != nil {
+		gc.cc = &faultConn{gc: gc, fi: p.opt.FaultInjector}
+	}
+	if p.opt.Debug {
+		gc.gauge = connection.WithLabelValues(gc.label())
+	}
+	if p.opt.CircuitBreaker != nil {
+		gc.breaker = newBreaker(p.opt.CircuitBreaker, p.opt.Clock)
 	}
+	if p.opt.Limiter != nil {
+		gc.limiter = p.opt.Limiter(gc.streamLimit())
+		if cs, ok := gc.limiter.(clockSetter); ok {
+			cs.setClock(p.opt.Clock)
+		}
+		gc.setCapacity(gc.limiter.Limit())
+	}
+	if streams != nil {
+		// 服务端随时可能发送新的 SETTINGS，不必等到下次清理
+		streams.watch(gc.refreshCapacity)
+		gc.refreshCapacity()
+	}
+	p.event(EventConnCreated, gc.id, conn.Target())
+	return gc
 }
 
-func (gc *grpcConn) get() (lc LogicConn, err error) {
-	current := atomic.LoadInt32(&gc.current)
-	if current == 0 {
-		err = errGrpcOverload
-		return
+func (gc *grpcConn) get() (LogicConn, error) {
+	if atomic.LoadInt32(&gc.closed) == 1 {
+		return nil, ErrConnClosed
 	}
-
-	gc.lock.Lock()
-	defer gc.lock.Unlock()
-
-	if gc.conn.GetState() == connectivity.Shutdown {
-		err = ErrConnClosed
-		return
+	if atomic.LoadInt32(&gc.draining) == 1 {
+		return nil, errGrpcOverload
 	}
 
-	if gc.current == 0 {
-		err = errGrpcOverload
-		return
+	for {
+		current := atomic.LoadInt32(&gc.current)
+		if current <= 0 {
+			return nil, errGrpcOverload
+		}
+		if atomic.CompareAndSwapInt32(&gc.current, current, current-1) {
+			break
+		}
 	}
+	atomic.AddInt32(&gc.leases, 1)
+	// drain 可能在上面的检查之后开始，此时 checkDrained 可能没有看到这个租约，
+	// 归还名额并重新检查，保证 gc.drained 关闭后不再发放租约
+	if atomic.LoadInt32(&gc.draining) == 1 {
+		atomic.AddInt32(&gc.leases, -1)
+		atomic.AddInt32(&gc.current, 1)
+		gc.checkDrained()
+		return nil, errGrpcOverload
+	}
+	atomic.StoreInt64(&gc.ts, gc.p.opt.Clock.Now().UnixNano())
 
-	gc.ts = time.Now()
-	atomic.AddInt32(&gc.current, -1)
-
-	logicconn := logicConnPool.Get().(logicConn)
+	logicconn := logicConnPool.Get().(*logicConn)
 	logicconn.gconn = gc
-	logicconn.ClientConnInterface = gc.conn
-	if gc.p.opt.Debug {
-		connection.WithLabelValues(fmt.Sprintf("conn-%d", gc.id)).Add(1)
+	logicconn.ClientConnInterface = gc.cc
+	if gc.gauge != nil {
+		gc.gauge.Inc()
 	}
 	return logicconn, nil
 }
 
-func (gc *grpcConn) recycle(lc logicConn) {
+func (gc *grpcConn) recycle(lc *logicConn) {
+	atomic.AddInt32(&gc.leases, -1)
 	current := atomic.AddInt32(&gc.current, 1)
-	if int(current) > gc.maxStreamsClient {
+	if !gc.resizable && current > atomic.LoadInt32(&gc.maxStreamsClient) {
 		panic("Unknown error")
 	}
-	if gc.p.opt.Debug {
-		connection.WithLabelValues(fmt.Sprintf("conn-%d", gc.id)).Sub(1)
+	if atomic.LoadInt32(&gc.draining) == 1 {
+		gc.checkDrained()
+	}
+	if gc.gauge != nil {
+		gc.gauge.Dec()
 	}
 	lc.gconn = nil
+	lc.admitted = 0
+	lc.tenant = nil
 	lc.ClientConnInterface = nil
 	logicConnPool.Put(lc)
 }
 
+// kill 模拟连接中断，不再发放租约，由 cleanPeriodically 关闭并移除
+func (gc *grpcConn) kill() {
+	atomic.StoreInt32(&gc.closed, 1)
+}
+
+// isKilled 连接是否被 kill 标记为中断，底层连接可能仍然 READY
+func (gc *grpcConn) isKilled() bool {
+	return atomic.LoadInt32(&gc.closed) == 1
+}
+
 func (gc *grpcConn) isClosed() bool {
-	return gc.conn.GetState() == connectivity.Shutdown
+	return atomic.LoadInt32(&gc.closed) == 1 || gc.conn.GetState() == connectivity.Shutdown
+}
+
+// drain 停止发放租约，租约全部归还后由 cleanPeriodically 关闭
+func (gc *grpcConn) drain() bool {
+	if !atomic.CompareAndSwapInt32(&gc.draining, 0, 1) {
+		return false
+	}
+	gc.checkDrained()
+	return true
+}
+
+// checkDrained 租约全部归还后关闭 gc.drained
+func (gc *grpcConn) checkDrained() {
+	if gc.inflight() <= 0 {
+		gc.drainedOnce.Do(func() { close(gc.drained) })
+	}
+}
+
+func (gc *grpcConn) isDrained() bool {
+	return atomic.LoadInt32(&gc.draining) == 1 && gc.inflight() <= 0
+}
+
+func (gc *grpcConn) isReady() bool {
+	return gc.conn.GetState() == connectivity.Ready
 }
 
 func (gc *grpcConn) isIdle() bool {
-	return int(atomic.LoadInt32(&gc.current)) == gc.maxStreamsClient
+	return atomic.LoadInt32(&gc.leases) == 0
+}
+
+// capacity 当前容量
+func (gc *grpcConn) capacity() int {
+	return int(atomic.LoadInt32(&gc.maxStreamsClient))
+}
+
+// inflight 当前正在使用的租约数。单独计数，不从容量和剩余可用推算：
+// setCapacity 分两步修改这两个值，中间推算出的结果可能为负数
+func (gc *grpcConn) inflight() int {
+	return int(atomic.LoadInt32(&gc.leases))
+}
+
+// streamLimit 连接的最大容量，见 WithStreamLimitDiscovery
+func (gc *grpcConn) streamLimit() int {
+	switch {
+	case gc.streams != nil:
+		return gc.p.streamLimit(gc.streams.get())
+	case gc.p.opt.StreamLimit != nil:
+		return gc.p.streamLimit(gc.p.opt.StreamLimit.Limit())
+	}
+	return gc.p.opt.MaxStreamsClient
+}
+
+// refreshCapacity 按服务端的流数限制调整容量
+func (gc *grpcConn) refreshCapacity() {
+	max := gc.streamLimit()
+	if gc.limiter != nil && gc.capacity() <= max {
+		return
+	}
+	gc.setCapacity(max)
+}
+
+// setCapacity 调整容量，剩余可用数随之增减，可能暂时为负数
+func (gc *grpcConn) setCapacity(n int) {
+	gc.limitMux.Lock()
+	defer gc.limitMux.Unlock()
+
+	delta := int32(n) - atomic.LoadInt32(&gc.maxStreamsClient)
+	if delta == 0 {
+		return
+	}
+	atomic.StoreInt32(&gc.maxStreamsClient, int32(n))
+	atomic.AddInt32(&gc.current, delta)
+}
+
+// take 经过熔断器检查后获取租约，获取失败时归还半开状态的探测名额
+func (gc *grpcConn) take() (LogicConn, error) {
+	if gc.breaker == nil {
+		return gc.get()
+	}
+	ok, probe := gc.breaker.allow()
+	if !ok {
+		return nil, ErrCircuitOpen
+	}
+	logicconn, err := gc.get()
+	if err != nil && probe {
+		gc.breaker.cancelProbe()
+	}
+	return logicconn, err
+}
+
+// isBroken 熔断器是否处于熔断状态，或者半开状态下没有剩余的探测名额
+func (gc *grpcConn) isBroken() bool {
+	return gc.breaker != nil && gc.breaker.blocked()
+}
+
+// report 上报调用耗时及结果
+func (gc *grpcConn) report(rtt time.Duration, err error) {
+	if gc.breaker != nil {
+		gc.breaker.onResult(err)
+	}
+	if gc.limiter != nil {
+		limit := gc.limiter.OnSample(rtt, gc.inflight(), isOverload(err))
+		if max := gc.streamLimit(); limit > max {
+			limit = max
+		}
+		gc.setCapacity(limit)
+	}
+}
+
+// markSuspect 标记连接可疑，下次清理时检查连接状态
+func (gc *grpcConn) markSuspect() {
+	if atomic.CompareAndSwapInt32(&gc.suspect, 0, 1) {
+		gc.p.event(EventConnSuspect, gc.id, "")
+	}
+}
+
+// isUnhealthy 检查被标记为可疑的连接，连接状态正常时清除标记
+func (gc *grpcConn) isUnhealthy() bool {
+	if atomic.LoadInt32(&gc.suspect) == 0 {
+		return false
+	}
+
+	if gc.isFailing() {
+		return true
+	}
+	atomic.StoreInt32(&gc.suspect, 0)
+	return false
+}
+
+// isFailing 连接是否处于连接失败或者已关闭的状态，IDLE 与 CONNECTING 的连接
+// 在调用时会建立连接，不算失败
+func (gc *grpcConn) isFailing() bool {
+	switch gc.conn.GetState() {
+	case connectivity.TransientFailure, connectivity.Shutdown:
+		return true
+	}
+	return false
+}
+
+// idleTimeoutReason 空闲超时的清理原因，见 WithMinReady
+const idleTimeoutReason = "idle timeout"
+
+// evictReason 连接需要被清理时返回原因，否则返回空字符串
+func (gc *grpcConn) evictReason() string {
+	switch {
+	case gc.isClosed():
+		return "closed"
+	case gc.isDrained():
+		return "drained"
+	case gc.isTimeout():
+		return idleTimeoutReason
+	case gc.isUnhealthy():
+		return "unhealthy"
+	}
+	return ""
 }
 
 func (gc *grpcConn) isTimeout() bool {
-	return time.Now().Sub(gc.ts) > gc.clientIdleTimeout
+	return gc.p.opt.Clock.Now().Sub(time.Unix(0, atomic.LoadInt64(&gc.ts))) > gc.clientIdleTimeout
+}
+
+// label Debug 模式下的指标标签
+func (gc *grpcConn) label() string {
+	return "conn-" + strconv.Itoa(int(gc.id))
 }
 
 func (gc *grpcConn) close() (err error) {
-	gc.lock.Lock()
-	defer gc.lock.Unlock()
+	atomic.StoreInt32(&gc.closed, 1)
+	if gc.gauge != nil {
+		connection.DeleteLabelValues(gc.label())
+	}
+
+	if gc.channelzToken != 0 {
+		channelzIDs.Delete(gc.channelzToken)
+	}
 
 	err = gc.conn.Close()
 	if err != nil {
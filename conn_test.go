@@ -0,0 +1,217 @@
+package grpcpool
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hunyxv/grpcpool/internal"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+)
+
+func newTestGrpcConn(tb testing.TB, p *Pool) *grpcConn {
+	conn, err := offlineBuilder()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return newGrpcConn(p, conn, nil)
+}
+
+func TestTakeKeepsProbe(t *testing.T) {
+	clock := &fixedClock{now: time.Unix(1, 0)}
+	p := newTestPool(t,
+		WithMaxIdle(0),
+		WithMaxStreamsClient(1),
+		WithClock(clock),
+		WithCleanIntervalTime(time.Hour),
+		WithCircuitBreaker(BreakerConfig{
+			Window:         time.Minute,
+			MinRequests:    1,
+			ErrorRate:      0.5,
+			Cooldown:       time.Second,
+			HalfOpenProbes: 1,
+			FailureCodes:   []codes.Code{codes.Unavailable},
+		}))
+	defer p.Close()
+	gc := newTestGrpcConn(t, p)
+
+	lc, err := gc.take()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gc.report(time.Millisecond, status.Error(codes.Unavailable, "down"))
+	clock.now = clock.now.Add(time.Second)
+
+	// 连接已满，半开状态的探测名额没有发放出去，归还后仍然可以探测
+	if _, err := gc.take(); err != errGrpcOverload {
+		t.Fatalf("take on a full conn: %v, want %v", err, errGrpcOverload)
+	}
+	if gc.isBroken() {
+		t.Fatal("probe lost on a failed take")
+	}
+	gc.recycle(lc.(*logicConn))
+	if _, err := gc.take(); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if !gc.isBroken() {
+		t.Fatal("half-open conn without probes left is not broken")
+	}
+}
+
+func TestGrpcConnStress(t *testing.T) {
+	p := newTestPool(t, WithMaxIdle(0), WithMaxStreamsClient(8))
+	defer p.Close()
+	gc := newTestGrpcConn(t, p)
+
+	var (
+		wg     sync.WaitGroup
+		leased int32
+	)
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				lc, err := gc.get()
+				if err == errGrpcOverload {
+					continue
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if n := atomic.AddInt32(&leased, 1); n > 8 {
+					t.Errorf("%d leases on a connection with capacity 8", n)
+				}
+				atomic.AddInt32(&leased, -1)
+				gc.recycle(lc.(*logicConn))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if !gc.isIdle() || gc.inflight() != 0 {
+		t.Fatalf("current = %d, want %d", atomic.LoadInt32(&gc.current), gc.capacity())
+	}
+
+	if err := gc.close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gc.get(); err != ErrConnClosed {
+		t.Fatalf("get on closed conn: %v, want %v", err, ErrConnClosed)
+	}
+}
+
+// TestDrainWhileResizing 容量调整期间检查排空，仍有租约时不能认为已排空
+func TestDrainWhileResizing(t *testing.T) {
+	p := newTestPool(t, WithMaxIdle(0), WithMaxStreamsClient(4))
+	defer p.Close()
+	gc := newTestGrpcConn(t, p)
+
+	lc, err := gc.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gc.drain()
+
+	// 单核环境下也让两个 goroutine 并行交错
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			gc.setCapacity(1)
+			gc.setCapacity(4)
+		}
+	}()
+	for i := 0; i < 100000 && !t.Failed(); i++ {
+		gc.checkDrained()
+		select {
+		case <-gc.drained:
+			t.Error("drained with a lease in flight")
+		default:
+		}
+		if in := gc.inflight(); in != 1 {
+			t.Errorf("inflight %d, want 1", in)
+		}
+	}
+	close(stop)
+	<-done
+
+	gc.recycle(lc.(*logicConn))
+	if !gc.isDrained() {
+		t.Fatal("conn not drained after the last lease is recycled")
+	}
+}
+
+// lockedSlots 原先基于自旋锁的实现，用于对比
+type lockedSlots struct {
+	gc      *grpcConn
+	current int32
+	lock    interface {
+		Lock()
+		Unlock()
+	}
+	ts time.Time
+}
+
+func (ls *lockedSlots) get() bool {
+	if atomic.LoadInt32(&ls.current) == 0 {
+		return false
+	}
+
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+
+	if ls.gc.conn.GetState() == connectivity.Shutdown || ls.current == 0 {
+		return false
+	}
+	ls.ts = time.Now()
+	atomic.AddInt32(&ls.current, -1)
+	return true
+}
+
+func (ls *lockedSlots) recycle() {
+	atomic.AddInt32(&ls.current, 1)
+}
+
+func BenchmarkSlotAcquire(b *testing.B) {
+	p := newTestPool(b, WithMaxIdle(0))
+	defer p.Close()
+
+	b.Run("cas", func(b *testing.B) {
+		gc := newTestGrpcConn(b, p)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if lc, err := gc.get(); err == nil {
+					gc.recycle(lc.(*logicConn))
+				}
+			}
+		})
+	})
+
+	b.Run("spinlock", func(b *testing.B) {
+		ls := &lockedSlots{
+			gc:      newTestGrpcConn(b, p),
+			current: int32(defaultMaxStreamsClient),
+			lock:    internal.NewSpinLock(),
+		}
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if ls.get() {
+					ls.recycle()
+				}
+			}
+		})
+	})
+}
@@ -0,0 +1,88 @@
+package grpcpool
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Drain stops leasing the grpcConn with the given id, it is closed and
+// replaced once its outstanding leases are returned.
+func (p *Pool) Drain(id int32) error {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+
+	if atomic.LoadInt32(&p.state) == CLOSED {
+		return ErrPoolClosed
+	}
+	i := p.indexOf(id)
+	if i < 0 {
+		return ErrConnNotFound
+	}
+	if p.conns[i].drain() {
+		p.event(EventConnDraining, id, "")
+	}
+	return nil
+}
+
+// Evict closes the grpcConn with the given id at once and removes it from
+// the pool, calls in flight on it fail.
+func (p *Pool) Evict(id int32) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if atomic.LoadInt32(&p.state) == CLOSED {
+		return ErrPoolClosed
+	}
+	i := p.indexOf(id)
+	if i < 0 {
+		return ErrConnNotFound
+	}
+	p.removeConn(i, "evicted")
+	p.reshard()
+	return nil
+}
+
+// Resize changes MaxIdle and GrpcPoolSize of the pool at runtime. Idle
+// connections beyond the new pool size are closed at once, busy ones are
+// left to the cleaner.
+func (p *Pool) Resize(maxIdle, poolSize int) error {
+	if poolSize < 1 || maxIdle < 0 || maxIdle > poolSize {
+		return ErrInvalidSize
+	}
+
+	p.mux.Lock()
+	if atomic.LoadInt32(&p.state) == CLOSED {
+		p.mux.Unlock()
+		return ErrPoolClosed
+	}
+	p.opt.MaxIdle = maxIdle
+	p.opt.GrpcPoolSize = poolSize
+	for i := 0; i < len(p.conns) && len(p.conns) > poolSize; {
+		if p.conns[i].isIdle() {
+			p.removeConn(i, "resize")
+			continue
+		}
+		i++
+	}
+	p.reshard()
+	if p.admission != nil && p.opt.MaxInFlight <= 0 {
+		// 容量由 GrpcPoolSize 换算而来，随之调整
+		p.admission.resize(p.opt.capacity())
+	}
+	p.event(EventPoolResized, 0, fmt.Sprintf("max idle %d, pool size %d", maxIdle, poolSize))
+	p.mux.Unlock()
+
+	// 立即按新的 MaxIdle 清理或补充空闲连接
+	p.clean()
+	return nil
+}
+
+// indexOf 返回 id 对应连接在 p.conns 中的下标，调用方需持有 p.mux
+func (p *Pool) indexOf(id int32) int {
+	for i, gc := range p.conns {
+		if gc.id == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,59 @@
+package grpcpool_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hunyxv/grpcpool"
+	"github.com/hunyxv/grpcpool/grpcpooltest"
+)
+
+func TestResizeConcurrentGet(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	p := newPool(t, s,
+		grpcpool.WithMaxIdle(1),
+		grpcpool.WithGrpcPoolSize(2),
+		grpcpool.WithMaxStreamsClient(1))
+	defer p.Close()
+
+	// 连接池满载时 Get 读取连接数上限，同时 Resize 修改它
+	var (
+		wg   sync.WaitGroup
+		stop int32
+		gets int32
+	)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt32(&stop) == 0 {
+				lc, err := p.Get()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				time.Sleep(time.Millisecond)
+				p.Put(lc)
+				atomic.AddInt32(&gets, 1)
+			}
+		}()
+	}
+	for i := 0; atomic.LoadInt32(&gets) < 100 && !t.Failed(); i++ {
+		if err := p.Resize(1, 2+i%2); err != nil {
+			t.Error(err)
+			break
+		}
+	}
+	if err := p.Resize(1, 3); err != nil {
+		t.Fatal(err)
+	}
+	atomic.StoreInt32(&stop, 1)
+	wg.Wait()
+
+	if st := p.Stats(); st.PoolSize != 3 || st.MaxIdle != 1 {
+		t.Fatalf("pool size %d, max idle %d after Resize, want 3 and 1", st.PoolSize, st.MaxIdle)
+	}
+}
@@ -0,0 +1,214 @@
+package grpcpool
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DebugHandler returns a http.Handler which serves the live state of pools:
+// options, connections, outstanding leases and recent lifecycle events.
+// It serves HTML by default and JSON for ?format=json or an Accept header
+// asking for application/json.
+//
+//	mux.Handle("/metrics", promhttp.Handler())
+//	mux.Handle("/debug/grpcpool", grpcpool.DebugHandler(pool))
+func DebugHandler(pools ...*Pool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snapshots := make([]poolSnapshot, 0, len(pools))
+		for _, p := range pools {
+			snapshots = append(snapshots, p.snapshot())
+		}
+
+		if r.URL.Query().Get("format") == "json" ||
+			strings.Contains(r.Header.Get("Accept"), "application/json") {
+			w.Header().Set("Content-Type", "application/json")
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(snapshots); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := debugTemplate.Execute(w, snapshots); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// poolSnapshot DebugHandler 展示的连接池状态
+type poolSnapshot struct {
+	Name        string
+	Closed      bool
+	Options     map[string]interface{}
+	StreamLimit int
+	Conns       []connSnapshot
+	Leases      []leaseSnapshot
+	Events      []Event
+}
+
+type connSnapshot struct {
+	ID         int32
+	Target     string
+	ChannelzID int64  `json:",omitempty"`
+	Zone       string `json:",omitempty"`
+	State      string
+	InFlight   int
+	Limit      int
+	Age        string
+	Idle       string `json:",omitempty"`
+	Broken     bool   `json:",omitempty"`
+	Suspect    bool   `json:",omitempty"`
+}
+
+type leaseSnapshot struct {
+	Lease
+	Held string
+}
+
+func (p *Pool) snapshot() poolSnapshot {
+	now := p.opt.Clock.Now()
+	stats := p.Stats()
+
+	s := poolSnapshot{
+		Name:        stats.Name,
+		Closed:      stats.Closed,
+		StreamLimit: stats.StreamLimit,
+		Conns:       make([]connSnapshot, 0, len(stats.Conns)),
+		Events:      p.Events(),
+	}
+
+	p.mux.RLock()
+	// MaxIdle 和 GrpcPoolSize 可能被 Resize 修改
+	s.Options = p.opt.describe()
+	broken := make(map[int32]bool, len(p.conns))
+	suspect := make(map[int32]bool, len(p.conns))
+	for _, gc := range p.conns {
+		broken[gc.id] = gc.isBroken()
+		suspect[gc.id] = atomic.LoadInt32(&gc.suspect) == 1
+	}
+	p.mux.RUnlock()
+
+	for _, cs := range stats.Conns {
+		c := connSnapshot{
+			ID:         cs.ID,
+			Target:     cs.Target,
+			ChannelzID: cs.ChannelzID,
+			Zone:       cs.Locality.Zone,
+			State:      cs.State.String(),
+			InFlight:   cs.InFlight,
+			Limit:      cs.Limit,
+			Age:        roundDuration(now.Sub(cs.Created)),
+			Broken:     broken[cs.ID],
+			Suspect:    suspect[cs.ID],
+		}
+		if cs.InFlight == 0 {
+			c.Idle = roundDuration(now.Sub(cs.LastUsed))
+		}
+		s.Conns = append(s.Conns, c)
+	}
+
+	for _, l := range p.Leases() {
+		s.Leases = append(s.Leases, leaseSnapshot{Lease: l, Held: roundDuration(now.Sub(l.Acquired))})
+	}
+	return s
+}
+
+func roundDuration(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
+
+// describe 以键值对描述配置，用于 DebugHandler
+func (opt *option) describe() map[string]interface{} {
+	return map[string]interface{}{
+		"GrpcPoolSize":      opt.GrpcPoolSize,
+		"MaxStreamsClient":  opt.MaxStreamsClient,
+		"MaxIdle":           opt.MaxIdle,
+		"CleanIntervalTime": opt.CleanIntervalTime.String(),
+		"ClientIdleTimeout": opt.ClientIdleTimeout.String(),
+		"Nonblocking":       opt.Nonblocking,
+		"RetryMaxAttempts":  opt.RetryPolicy.MaxAttempts,
+		"CircuitBreaker":    opt.CircuitBreaker != nil,
+		"AdaptiveLimit":     opt.Limiter != nil,
+		"StreamLimit":       opt.StreamLimit != nil,
+		"MaxStreamLimit":    opt.MaxStreamLimit,
+		"Shards":            opt.Shards,
+		"HedgingRatio":      opt.HedgingRatio,
+		"HedgingMaxTokens":  opt.HedgingMaxTokens,
+		"MinReady":          opt.MinReady,
+		"RotateConcurrency": opt.RotateConcurrency,
+		"CredentialWatcher": opt.CredentialWatcher != nil,
+		"Channelz":          opt.Channelz != nil,
+		"FaultInjector":     opt.FaultInjector != nil && opt.FaultInjector.Enabled(),
+		"MaxInFlight":       opt.MaxInFlight,
+		"ShedMode":          opt.Shed.Mode.String(),
+		"PriorityReserve":   opt.PriorityReserve,
+		"Tenants":           len(opt.Tenants),
+		"LocalZone":         opt.LocalZone,
+		"Spillover":         opt.Spillover,
+		"RateLimit":         opt.describeRateLimits(),
+		"LeakTracking":      opt.LeakTracking,
+		"Debug":             opt.Debug,
+	}
+}
+
+var debugTemplate = template.Must(template.New("grpcpool").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>grpcpool</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 2px 8px; text-align: left; vertical-align: top; }
+pre { margin: 0; font-size: 12px; }
+</style>
+</head>
+<body>
+{{range .}}
+<h2>{{.Name}}{{if .Closed}} (closed){{end}}</h2>
+
+<h3>options</h3>
+<table>
+{{range $k, $v := .Options}}<tr><th>{{$k}}</th><td>{{$v}}</td></tr>
+{{end}}{{if .StreamLimit}}<tr><th>discovered stream limit</th><td>{{.StreamLimit}}</td></tr>{{end}}
+</table>
+
+<h3>connections ({{len .Conns}})</h3>
+<table>
+<tr><th>id</th><th>target</th><th>channelz</th><th>zone</th><th>state</th><th>in-flight/capacity</th><th>age</th><th>idle</th><th>flags</th></tr>
+{{range .Conns}}<tr><td>{{.ID}}</td><td>{{.Target}}</td><td>{{if .ChannelzID}}{{.ChannelzID}}{{end}}</td><td>{{.Zone}}</td><td>{{.State}}</td><td>{{.InFlight}}/{{.Limit}}</td><td>{{.Age}}</td><td>{{.Idle}}</td><td>{{if .Broken}}broken {{end}}{{if .Suspect}}suspect{{end}}</td></tr>
+{{end}}</table>
+
+{{if .Leases}}<h3>outstanding leases ({{len .Leases}})</h3>
+<table>
+<tr><th>conn</th><th>held</th><th>stack</th></tr>
+{{range .Leases}}<tr><td>{{.ConnID}}</td><td>{{.Held}}</td><td><pre>{{.Stack}}</pre></td></tr>
+{{end}}</table>
+{{end}}
+
+<h3>recent events</h3>
+<table>
+<tr><th>time</th><th>event</th><th>conn</th><th>reason</th></tr>
+{{range .Events}}<tr><td>{{.Time.Format "15:04:05.000"}}</td><td>{{.Type}}</td><td>{{if .ConnID}}{{.ConnID}}{{end}}</td><td>{{.Reason}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+// describeRateLimits 描述连接池以及按方法的限流
+func (opt *option) describeRateLimits() map[string]string {
+	limits := make(map[string]string, len(opt.MethodRateLimits)+1)
+	if opt.RateLimit != nil {
+		limits[poolRateKey] = opt.RateLimit.String()
+	}
+	for method, limit := range opt.MethodRateLimits {
+		limits[method] = limit.String()
+	}
+	return limits
+}
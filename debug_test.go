@@ -0,0 +1,87 @@
+package grpcpool_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hunyxv/grpcpool"
+	"github.com/hunyxv/grpcpool/grpcpooltest"
+)
+
+func TestDebugHandler(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	p := newPool(t, s, grpcpool.WithMaxIdle(2), grpcpool.WithLeakTracking())
+	defer p.Close()
+
+	lc, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := grpcpool.DebugHandler(p)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/grpcpool?format=json", nil))
+	var pools []struct {
+		Conns []struct {
+			ID       int32
+			InFlight int
+		}
+		Leases []grpcpool.Lease
+		Events []struct{ Type string }
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &pools); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body)
+	}
+	if len(pools) != 1 || len(pools[0].Conns) != 2 {
+		t.Fatalf("unexpected pools: %s", rec.Body)
+	}
+	if len(pools[0].Leases) != 1 || !strings.HasPrefix(pools[0].Leases[0].Stack, "github.com/hunyxv/grpcpool_test.TestDebugHandler\n") {
+		t.Fatalf("lease not tracked: %+v", pools[0].Leases)
+	}
+	if len(pools[0].Events) != 2 || pools[0].Events[0].Type != "conn_created" {
+		t.Fatalf("unexpected events: %+v", pools[0].Events)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/grpcpool", nil))
+	if !strings.Contains(rec.Body.String(), "outstanding leases (1)") {
+		t.Fatalf("html view misses the lease:\n%s", rec.Body)
+	}
+
+	p.Put(lc)
+	if leases := p.Leases(); len(leases) != 0 {
+		t.Fatalf("%d leases after Put", len(leases))
+	}
+}
+
+// TestLeaseStackStartsAtCaller 租约的调用栈从调用方开始，不包含连接池内部的调用
+func TestLeaseStackStartsAtCaller(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	p := newPool(t, s, grpcpool.WithMaxIdle(2), grpcpool.WithLeakTracking())
+	defer p.Close()
+
+	for _, get := range []func() (grpcpool.LogicConn, error){
+		p.Get,
+		func() (grpcpool.LogicConn, error) { return p.GetContext(context.Background()) },
+	} {
+		lc, err := get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		leases := p.Leases()
+		p.Put(lc)
+		if len(leases) != 1 {
+			t.Fatalf("%d leases, want 1", len(leases))
+		}
+		first := strings.SplitN(leases[0].Stack, "\n", 2)[0]
+		if !strings.HasPrefix(first, "github.com/hunyxv/grpcpool_test.TestLeaseStackStartsAtCaller") {
+			t.Fatalf("stack starts at %s:\n%s", first, leases[0].Stack)
+		}
+	}
+}
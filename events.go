@@ -0,0 +1,164 @@
+package grpcpool
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultEventLogSize   = 128
+	subscriberChannelSize = 64
+)
+
+// EventType 连接池生命周期事件类型
+type EventType int
+
+const (
+	// EventConnCreated a grpcConn was dialed and added to the pool.
+	EventConnCreated EventType = iota
+
+	// EventConnClosed a grpcConn was closed and removed from the pool.
+	EventConnClosed
+
+	// EventConnSuspect a grpcConn was marked suspect after a failed call.
+	EventConnSuspect
+
+	// EventConnDraining a grpcConn stopped accepting leases and will be
+	// closed once the outstanding leases are returned.
+	EventConnDraining
+
+	// EventPoolResized MaxIdle or GrpcPoolSize of the pool was changed.
+	EventPoolResized
+
+	// EventPoolClosed the pool was closed.
+	EventPoolClosed
+)
+
+var eventTypeNames = [...]string{
+	EventConnCreated:  "conn_created",
+	EventConnClosed:   "conn_closed",
+	EventConnSuspect:  "conn_suspect",
+	EventConnDraining: "conn_draining",
+	EventPoolResized:  "pool_resized",
+	EventPoolClosed:   "pool_closed",
+}
+
+func (t EventType) String() string {
+	if t < 0 || int(t) >= len(eventTypeNames) {
+		return "unknown"
+	}
+	return eventTypeNames[t]
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (t EventType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// Event 连接池生命周期事件
+type Event struct {
+	Time   time.Time
+	Type   EventType
+	ConnID int32  `json:",omitempty"`
+	Reason string `json:",omitempty"`
+}
+
+// eventLog 保存最近的事件，写满后覆盖最旧的事件，并转发给订阅者
+type eventLog struct {
+	mux  sync.Mutex
+	buf  []Event
+	next int
+	full bool
+	subs map[chan Event]struct{} // 连接池关闭后为 nil
+}
+
+func newEventLog(size int) *eventLog {
+	return &eventLog{
+		buf:  make([]Event, size),
+		subs: make(map[chan Event]struct{}),
+	}
+}
+
+func (el *eventLog) add(e Event) {
+	el.mux.Lock()
+	el.buf[el.next] = e
+	el.next++
+	if el.next == len(el.buf) {
+		el.next = 0
+		el.full = true
+	}
+	for ch := range el.subs {
+		// 订阅者处理不及时则丢弃事件，不阻塞连接池
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+	el.mux.Unlock()
+}
+
+func (el *eventLog) subscribe() (<-chan Event, func()) {
+	el.mux.Lock()
+	defer el.mux.Unlock()
+
+	ch := make(chan Event, subscriberChannelSize)
+	if el.subs == nil {
+		close(ch)
+		return ch, func() {}
+	}
+	el.subs[ch] = struct{}{}
+	return ch, func() {
+		el.mux.Lock()
+		defer el.mux.Unlock()
+		if _, ok := el.subs[ch]; ok {
+			delete(el.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// closeSubscribers 关闭所有订阅者的 channel，之后的订阅立即结束
+func (el *eventLog) closeSubscribers() {
+	el.mux.Lock()
+	defer el.mux.Unlock()
+
+	for ch := range el.subs {
+		close(ch)
+	}
+	el.subs = nil
+}
+
+// recent 按时间顺序返回保存的事件
+func (el *eventLog) recent() []Event {
+	el.mux.Lock()
+	defer el.mux.Unlock()
+
+	if !el.full {
+		return append([]Event(nil), el.buf[:el.next]...)
+	}
+	events := make([]Event, 0, len(el.buf))
+	events = append(events, el.buf[el.next:]...)
+	return append(events, el.buf[:el.next]...)
+}
+
+// event 记录一个事件
+func (p *Pool) event(typ EventType, connID int32, reason string) {
+	p.events.add(Event{
+		Time:   p.opt.Clock.Now(),
+		Type:   typ,
+		ConnID: connID,
+		Reason: reason,
+	})
+}
+
+// Events returns the recent lifecycle events of the pool, oldest first.
+func (p *Pool) Events() []Event {
+	return p.events.recent()
+}
+
+// Subscribe returns a channel receiving the lifecycle events of the pool
+// from now on and a function to cancel the subscription. Events are dropped
+// when the channel is full. The channel is closed when the pool is closed.
+func (p *Pool) Subscribe() (<-chan Event, func()) {
+	return p.events.subscribe()
+}
@@ -0,0 +1,147 @@
+package grpcpool
+
+import (
+	"context"
+	"math/rand"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FaultRule 故障注入规则
+type FaultRule struct {
+	// Method 匹配的完整方法名，支持 path.Match 通配符，
+	// 例如 "/pb.HelloService/*"，为空时匹配所有方法
+	Method string
+
+	// Rate 注入概率，取值 [0, 1]
+	Rate float64
+
+	// Code 注入的错误码，codes.OK 表示不注入错误
+	Code codes.Code
+
+	// Delay 调用前增加的延迟
+	Delay time.Duration
+
+	// KillConn 模拟连接中断，调用返回 codes.Unavailable，
+	// 该 grpcConn 不再发放租约并由 cleanPeriodically 移除
+	KillConn bool
+}
+
+// FaultInjector injects errors, latency and connection deaths into the
+// calls made on leased connections, see WithFaultInjector.
+// It can be enabled, disabled and reconfigured at runtime.
+type FaultInjector struct {
+	enabled int32
+
+	mux   sync.RWMutex
+	rules []FaultRule
+
+	rmux sync.Mutex
+	r    *rand.Rand
+}
+
+// NewFaultInjector creates an enabled FaultInjector with rules.
+func NewFaultInjector(rules ...FaultRule) *FaultInjector {
+	return &FaultInjector{
+		enabled: 1,
+		rules:   rules,
+		r:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Enable enables fault injection.
+func (fi *FaultInjector) Enable() {
+	atomic.StoreInt32(&fi.enabled, 1)
+}
+
+// Disable disables fault injection, calls go straight to the connection.
+func (fi *FaultInjector) Disable() {
+	atomic.StoreInt32(&fi.enabled, 0)
+}
+
+// Enabled reports whether fault injection is enabled.
+func (fi *FaultInjector) Enabled() bool {
+	return atomic.LoadInt32(&fi.enabled) == 1
+}
+
+// SetRules replaces the rules of the injector.
+func (fi *FaultInjector) SetRules(rules ...FaultRule) {
+	fi.mux.Lock()
+	fi.rules = rules
+	fi.mux.Unlock()
+}
+
+// pick 返回命中的规则
+func (fi *FaultInjector) pick(method string) (FaultRule, bool) {
+	if !fi.Enabled() {
+		return FaultRule{}, false
+	}
+
+	fi.mux.RLock()
+	defer fi.mux.RUnlock()
+
+	for _, rule := range fi.rules {
+		if rule.Method != "" {
+			if ok, _ := path.Match(rule.Method, method); !ok {
+				continue
+			}
+		}
+		if fi.roll(rule.Rate) {
+			return rule, true
+		}
+	}
+	return FaultRule{}, false
+}
+
+func (fi *FaultInjector) roll(rate float64) bool {
+	fi.rmux.Lock()
+	defer fi.rmux.Unlock()
+	return fi.r.Float64() < rate
+}
+
+// faultConn 在租约连接上注入故障
+type faultConn struct {
+	gc *grpcConn
+	fi *FaultInjector
+}
+
+func (fc *faultConn) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	if err := fc.inject(ctx, method); err != nil {
+		return err
+	}
+	return fc.gc.conn.Invoke(ctx, method, args, reply, opts...)
+}
+
+func (fc *faultConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if err := fc.inject(ctx, method); err != nil {
+		return nil, err
+	}
+	return fc.gc.conn.NewStream(ctx, desc, method, opts...)
+}
+
+func (fc *faultConn) inject(ctx context.Context, method string) error {
+	rule, ok := fc.fi.pick(method)
+	if !ok {
+		return nil
+	}
+
+	if rule.Delay > 0 {
+		if err := fc.gc.p.sleep(ctx, rule.Delay); err != nil {
+			return status.FromContextError(err).Err()
+		}
+	}
+	if rule.KillConn {
+		fc.gc.kill()
+		return status.Error(codes.Unavailable, "grpcpool: injected connection death")
+	}
+	if rule.Code != codes.OK {
+		return status.Error(rule.Code, "grpcpool: injected fault")
+	}
+	return nil
+}
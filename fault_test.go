@@ -0,0 +1,139 @@
+package grpcpool_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hunyxv/grpcpool"
+	"github.com/hunyxv/grpcpool/grpcpooltest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// invokeEcho 不重试地调用 EchoMethod
+func invokeEcho(p *grpcpool.Pool) error {
+	return p.Invoke(context.Background(), grpcpooltest.EchoMethod, new(emptypb.Empty), new(emptypb.Empty), grpcpool.Retry(0))
+}
+
+func TestFaultInjectorCodes(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	fi := grpcpool.NewFaultInjector(grpcpool.FaultRule{
+		Method: "/grpcpooltest.Echo/*",
+		Rate:   1,
+		Code:   codes.PermissionDenied,
+	})
+	p := newPool(t, s, grpcpool.WithFaultInjector(fi))
+	defer p.Close()
+
+	if err := invokeEcho(p); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("err = %v, want PermissionDenied", err)
+	}
+	if calls := s.Calls(); calls != 0 {
+		t.Fatalf("server got %d calls, want 0", calls)
+	}
+
+	fi.SetRules(grpcpool.FaultRule{Method: "/other.Service/*", Rate: 1, Code: codes.Internal})
+	if err := invokeEcho(p); err != nil {
+		t.Fatalf("unmatched method: %v", err)
+	}
+
+	fi.SetRules(grpcpool.FaultRule{Rate: 1, Code: codes.Internal})
+	fi.Disable()
+	if err := invokeEcho(p); err != nil {
+		t.Fatalf("disabled injector: %v", err)
+	}
+}
+
+func TestFaultInjectorKillConn(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	fi := grpcpool.NewFaultInjector(grpcpool.FaultRule{Rate: 1, KillConn: true})
+	p := newPool(t, s,
+		grpcpool.WithFaultInjector(fi),
+		grpcpool.WithMaxIdle(1),
+		grpcpool.WithCleanIntervalTime(10*time.Millisecond),
+	)
+	defer p.Close()
+
+	before := connIDs(p)
+	if err := invokeEcho(p); status.Code(err) != codes.Unavailable {
+		t.Fatalf("err = %v, want Unavailable", err)
+	}
+	fi.Disable()
+
+	// 被杀死的连接由清理协程移除并补充新连接
+	waitFor(t, func() bool {
+		ids := connIDs(p)
+		for id := range before {
+			if ids[id] {
+				return false
+			}
+		}
+		return len(ids) == 1
+	})
+	if err := invokeEcho(p); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFaultInjectorKillConnRetried(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	fi := grpcpool.NewFaultInjector(grpcpool.FaultRule{Rate: 1, KillConn: true})
+	p := newPool(t, s,
+		grpcpool.WithFaultInjector(fi),
+		grpcpool.WithMaxIdle(2),
+		grpcpool.WithCleanIntervalTime(time.Hour),
+		grpcpool.WithRetryPolicy(grpcpool.RetryPolicy{MaxAttempts: 2}))
+	defer p.Close()
+
+	// 注入的连接中断属于连接级错误，非幂等调用也换一个连接重试，两个连接都被标记可疑
+	err := p.Invoke(context.Background(), grpcpooltest.EchoMethod, new(emptypb.Empty), new(emptypb.Empty),
+		grpcpool.MarkSuspect())
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("err = %v, want Unavailable", err)
+	}
+	ids := connIDs(p)
+	if len(ids) != 2 {
+		t.Fatalf("%d conns, want 2", len(ids))
+	}
+	for id := range ids {
+		if !hasEvent(p, grpcpool.EventConnSuspect, id, "") {
+			t.Fatalf("conn %d not marked suspect: %v", id, p.Events())
+		}
+	}
+}
+
+func TestFaultInjectorDelayWithFakeClock(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	clock := grpcpooltest.NewFakeClock(time.Now())
+	fi := grpcpool.NewFaultInjector(grpcpool.FaultRule{Rate: 1, Delay: time.Second})
+	p := newPool(t, s,
+		grpcpool.WithClock(clock),
+		grpcpool.WithFaultInjector(fi),
+		grpcpool.WithCleanIntervalTime(time.Hour),
+	)
+	defer p.Close()
+	waitFor(t, func() bool { return clock.Waiters() == 1 })
+
+	done := make(chan error, 1)
+	go func() {
+		done <- invokeEcho(p)
+	}()
+
+	// 注入的延迟按连接池的时钟计时
+	waitFor(t, func() bool { return clock.Waiters() == 2 })
+	select {
+	case err := <-done:
+		t.Fatalf("call returned %v before the delay", err)
+	default:
+	}
+	clock.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,139 @@
+package grpcpooltest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hunyxv/grpcpool"
+)
+
+var _ grpcpool.Clock = (*FakeClock)(nil)
+
+// FakeClock is a grpcpool.Clock which only moves when Advance is called.
+type FakeClock struct {
+	mux     sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// NewFakeClock creates a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// fakeWaiter 假时钟上的 ticker 或 timer
+type fakeWaiter struct {
+	c        *FakeClock
+	deadline time.Time
+	period   time.Duration // ticker 的周期，timer 为 0
+	ch       chan time.Time
+	f        func()
+}
+
+// Now returns the current time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.now
+}
+
+// NewTicker creates a ticker which ticks every d of fake time.
+func (c *FakeClock) NewTicker(d time.Duration) grpcpool.Ticker {
+	if d <= 0 {
+		panic("grpcpooltest: non-positive interval for NewTicker")
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	w := &fakeWaiter{c: c, deadline: c.now.Add(d), period: d, ch: make(chan time.Time, 1)}
+	c.waiters = append(c.waiters, w)
+	return fakeTicker{w}
+}
+
+// AfterFunc calls f after d of fake time, f is called synchronously by
+// Advance.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) grpcpool.Timer {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	w := &fakeWaiter{c: c, deadline: c.now.Add(d), f: f}
+	c.waiters = append(c.waiters, w)
+	return fakeTimer{w}
+}
+
+// Waiters returns the number of active tickers and timers, tests can use it
+// to wait until the code under test has armed its timers.
+func (c *FakeClock) Waiters() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return len(c.waiters)
+}
+
+// Advance moves the clock forward by d, firing tickers and timers in
+// deadline order.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mux.Lock()
+	target := c.now.Add(d)
+	for {
+		sort.Slice(c.waiters, func(i, j int) bool {
+			return c.waiters[i].deadline.Before(c.waiters[j].deadline)
+		})
+		if len(c.waiters) == 0 || c.waiters[0].deadline.After(target) {
+			break
+		}
+
+		w := c.waiters[0]
+		c.now = w.deadline
+		if w.period > 0 {
+			w.deadline = w.deadline.Add(w.period)
+			select {
+			case w.ch <- c.now:
+			default:
+			}
+			continue
+		}
+
+		c.waiters = c.waiters[1:]
+		c.mux.Unlock()
+		w.f()
+		c.mux.Lock()
+	}
+	c.now = target
+	c.mux.Unlock()
+}
+
+// stop 从时钟上移除，已经触发或停止时返回 false
+func (w *fakeWaiter) stop() bool {
+	w.c.mux.Lock()
+	defer w.c.mux.Unlock()
+
+	for i, o := range w.c.waiters {
+		if o == w {
+			w.c.waiters = append(w.c.waiters[:i], w.c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+func (t fakeTicker) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t fakeTicker) Stop() {
+	t.stop()
+}
+
+type fakeTimer struct {
+	*fakeWaiter
+}
+
+func (t fakeTimer) Stop() bool {
+	return t.stop()
+}
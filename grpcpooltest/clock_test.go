@@ -0,0 +1,46 @@
+package grpcpooltest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(0, 0)
+	c := NewFakeClock(start)
+
+	ticker := c.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var fired []time.Time
+	c.AfterFunc(1500*time.Millisecond, func() {
+		fired = append(fired, c.Now())
+	})
+	stopped := c.AfterFunc(time.Second, func() {
+		t.Error("stopped timer fired")
+	})
+	if !stopped.Stop() {
+		t.Fatal("Stop on pending timer returned false")
+	}
+
+	c.Advance(999 * time.Millisecond)
+	select {
+	case <-ticker.C():
+		t.Fatal("ticker fired early")
+	default:
+	}
+
+	c.Advance(time.Second)
+	if got := <-ticker.C(); !got.Equal(start.Add(time.Second)) {
+		t.Fatalf("tick at %v, want %v", got, start.Add(time.Second))
+	}
+	if len(fired) != 1 || !fired[0].Equal(start.Add(1500*time.Millisecond)) {
+		t.Fatalf("timer fired at %v", fired)
+	}
+	if now := c.Now(); !now.Equal(start.Add(1999 * time.Millisecond)) {
+		t.Fatalf("Now() = %v", now)
+	}
+	if n := c.Waiters(); n != 1 {
+		t.Fatalf("%d waiters, want the ticker only", n)
+	}
+}
@@ -0,0 +1,295 @@
+// Package grpcpooltest provides an in-process gRPC backend for testing
+// grpcpool without binding real ports.
+package grpcpooltest
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hunyxv/grpcpool"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// EchoMethod is the full method name served by Echo.
+const EchoMethod = "/grpcpooltest.Echo/Echo"
+
+const defaultBufSize = 1 << 20
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithMaxConcurrentStreams returns a Option which sets the HTTP/2
+// SETTINGS_MAX_CONCURRENT_STREAMS advertised by the server.
+func WithMaxConcurrentStreams(n uint32) Option {
+	return func(s *Server) {
+		s.maxStreams = n
+	}
+}
+
+// WithBufferSize returns a Option which sets the bufconn buffer size.
+func WithBufferSize(size int) Option {
+	return func(s *Server) {
+		s.bufSize = size
+	}
+}
+
+// Server is a bufconn backed gRPC server which echoes every request back,
+// whatever the method is. Latency, errors, GOAWAY and connection drops can
+// be injected at any time.
+type Server struct {
+	mux sync.Mutex
+	lis *listener
+	srv *grpc.Server
+
+	connMux sync.Mutex
+	conns   map[net.Conn]struct{}
+
+	bufSize    int
+	maxStreams uint32
+
+	latency int64 // time.Duration
+	errMux  sync.Mutex
+	errCode codes.Code
+	errRate float64
+	r       *rand.Rand
+
+	calls  int64
+	dials  int64
+	closed bool
+}
+
+// NewServer starts a Server.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		conns:   make(map[net.Conn]struct{}),
+		bufSize: defaultBufSize,
+		r:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, o := range opts {
+		o(s)
+	}
+
+	s.mux.Lock()
+	s.start()
+	s.mux.Unlock()
+	return s
+}
+
+// start 启动新的 grpc.Server，调用方需持有 s.mux
+func (s *Server) start() {
+	sopts := []grpc.ServerOption{grpc.UnknownServiceHandler(s.handle)}
+	if s.maxStreams > 0 {
+		sopts = append(sopts, grpc.MaxConcurrentStreams(s.maxStreams))
+	}
+
+	s.lis = &listener{Listener: bufconn.Listen(s.bufSize), s: s}
+	s.srv = grpc.NewServer(sopts...)
+	go s.srv.Serve(s.lis)
+}
+
+// Builder returns a grpcpool.Builder which dials the server, opts are
+// appended to the default dial options.
+func (s *Server) Builder(opts ...grpc.DialOption) grpcpool.Builder {
+	return func() (*grpc.ClientConn, error) {
+		return s.Dial(opts...)
+	}
+}
+
+// Dial creates a client connection to the server.
+func (s *Server) Dial(opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts = append([]grpc.DialOption{
+		grpc.WithInsecure(),
+		grpc.WithBlock(),
+		grpc.WithContextDialer(s.DialContext),
+	}, opts...)
+	return grpc.DialContext(ctx, "passthrough:///bufconn", opts...)
+}
+
+// DialContext dials a raw connection to the server, it can be used with
+// grpc.WithContextDialer.
+func (s *Server) DialContext(ctx context.Context, _ string) (net.Conn, error) {
+	s.mux.Lock()
+	lis := s.lis
+	s.mux.Unlock()
+
+	atomic.AddInt64(&s.dials, 1)
+	return lis.Dial()
+}
+
+// SetLatency sets the latency added to every call.
+func (s *Server) SetLatency(d time.Duration) {
+	atomic.StoreInt64(&s.latency, int64(d))
+}
+
+// SetError makes the server fail calls with code at the given rate,
+// rate is in [0, 1], 0 disables error injection.
+func (s *Server) SetError(code codes.Code, rate float64) {
+	s.errMux.Lock()
+	s.errCode = code
+	s.errRate = rate
+	s.errMux.Unlock()
+}
+
+// SetMaxConcurrentStreams changes the SETTINGS_MAX_CONCURRENT_STREAMS of
+// the server, existing connections receive a GOAWAY and new connections
+// see the new limit.
+func (s *Server) SetMaxConcurrentStreams(n uint32) {
+	s.mux.Lock()
+	s.maxStreams = n
+	s.mux.Unlock()
+	s.GoAway()
+}
+
+// GoAway sends GOAWAY on every existing connection and lets in-flight calls
+// finish, new connections are served by a fresh grpc.Server.
+func (s *Server) GoAway() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.closed {
+		return
+	}
+	old := s.srv
+	s.start()
+	go old.GracefulStop()
+}
+
+// DropConnections abruptly closes every accepted connection.
+func (s *Server) DropConnections() {
+	s.connMux.Lock()
+	conns := make([]net.Conn, 0, len(s.conns))
+	for conn := range s.conns {
+		conns = append(conns, conn)
+	}
+	s.connMux.Unlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+// Calls returns the number of calls received by the server.
+func (s *Server) Calls() int64 {
+	return atomic.LoadInt64(&s.calls)
+}
+
+// Dials returns the number of connections dialed to the server.
+func (s *Server) Dials() int64 {
+	return atomic.LoadInt64(&s.dials)
+}
+
+// Close stops the server immediately.
+func (s *Server) Close() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.closed = true
+	s.srv.Stop()
+}
+
+func (s *Server) handle(_ interface{}, stream grpc.ServerStream) error {
+	atomic.AddInt64(&s.calls, 1)
+
+	if d := time.Duration(atomic.LoadInt64(&s.latency)); d > 0 {
+		select {
+		case <-time.After(d):
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+	if err := s.injectedError(); err != nil {
+		return err
+	}
+	return EchoHandler(nil, stream)
+}
+
+// EchoHandler is a grpc.StreamHandler which echoes every request message
+// back, it can be used with grpc.UnknownServiceHandler to serve any method.
+func EchoHandler(_ interface{}, stream grpc.ServerStream) error {
+	// emptypb.Empty 会保留未知字段，任何 proto 消息都可以原样回显
+	for {
+		msg := new(emptypb.Empty)
+		if err := stream.RecvMsg(msg); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := stream.SendMsg(msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) injectedError() error {
+	s.errMux.Lock()
+	defer s.errMux.Unlock()
+
+	if s.errRate <= 0 || s.r.Float64() >= s.errRate {
+		return nil
+	}
+	return status.Error(s.errCode, "grpcpooltest: injected error")
+}
+
+func (s *Server) track(conn net.Conn) {
+	s.connMux.Lock()
+	s.conns[conn] = struct{}{}
+	s.connMux.Unlock()
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.connMux.Lock()
+	delete(s.conns, conn)
+	s.connMux.Unlock()
+}
+
+// listener 记录服务端接受的连接，用于模拟连接中断
+type listener struct {
+	*bufconn.Listener
+
+	s *Server
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	tc := &trackedConn{Conn: conn, s: l.s}
+	l.s.track(tc)
+	return tc, nil
+}
+
+type trackedConn struct {
+	net.Conn
+
+	s *Server
+}
+
+func (c *trackedConn) Close() error {
+	c.s.untrack(c)
+	return c.Conn.Close()
+}
+
+// Echo calls EchoMethod on cc with payload and returns the echoed payload.
+func Echo(ctx context.Context, cc grpc.ClientConnInterface, payload []byte, opts ...grpc.CallOption) ([]byte, error) {
+	reply := new(wrapperspb.BytesValue)
+	err := cc.Invoke(ctx, EchoMethod, wrapperspb.Bytes(payload), reply, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return reply.GetValue(), nil
+}
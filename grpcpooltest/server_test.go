@@ -0,0 +1,127 @@
+package grpcpooltest
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hunyxv/grpcpool"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newPool(t *testing.T, s *Server, opts ...grpcpool.Option) *grpcpool.Pool {
+	opts = append([]grpcpool.Option{grpcpool.WithLogger(log.New(ioutil.Discard, "", 0))}, opts...)
+	p, err := grpcpool.NewPool(s.Builder(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func echo(ctx context.Context, p *grpcpool.Pool, payload string) (string, error) {
+	var reply []byte
+	err := p.Do(ctx, func(cc grpc.ClientConnInterface) (err error) {
+		reply, err = Echo(ctx, cc, []byte(payload))
+		return
+	})
+	return string(reply), err
+}
+
+func TestEcho(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	p := newPool(t, s)
+	defer p.Close()
+
+	reply, err := echo(context.Background(), p, "hello")
+	if err != nil || reply != "hello" {
+		t.Fatalf("echo = %q, %v", reply, err)
+	}
+	if s.Calls() != 1 {
+		t.Fatalf("Calls() = %d, want 1", s.Calls())
+	}
+}
+
+func TestInjectedLatencyAndErrors(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	p := newPool(t, s)
+	defer p.Close()
+
+	s.SetLatency(50 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := echo(ctx, p, "slow"); status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("err = %v, want DeadlineExceeded", err)
+	}
+
+	s.SetLatency(0)
+	s.SetError(codes.Internal, 1)
+	if _, err := echo(context.Background(), p, "fail"); status.Code(err) != codes.Internal {
+		t.Fatalf("err = %v, want Internal", err)
+	}
+	s.SetError(codes.OK, 0)
+	if _, err := echo(context.Background(), p, "ok"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGoAwayAndDrop(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	p := newPool(t, s, grpcpool.WithMaxIdle(1))
+	defer p.Close()
+
+	for _, disrupt := range []func(){s.GoAway, s.DropConnections} {
+		dials := s.Dials()
+		disrupt()
+
+		// 连接断开后 grpc 自动重连，重试策略屏蔽中间的失败
+		deadline := time.Now().Add(5 * time.Second)
+		for s.Dials() == dials && time.Now().Before(deadline) {
+			echo(context.Background(), p, "reconnect")
+			time.Sleep(10 * time.Millisecond)
+		}
+		if s.Dials() == dials {
+			t.Fatal("client did not reconnect")
+		}
+		if _, err := echo(context.Background(), p, "after"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMaxConcurrentStreams(t *testing.T) {
+	s := NewServer(WithMaxConcurrentStreams(2))
+	defer s.Close()
+	s.SetLatency(100 * time.Millisecond)
+
+	cc, err := s.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := Echo(context.Background(), cc, nil); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 4 个调用在 2 个流上至少需要两轮
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatalf("4 calls with 2 streams took %v", elapsed)
+	}
+}
@@ -0,0 +1,177 @@
+package grpcpool
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+)
+
+// Hedge returns a CallOption which enables request hedging for Pool.Invoke.
+// The first attempt is sent on one grpcConn, if there's no response within
+// delay another attempt is sent on a different grpcConn, up to attempts in
+// total. The first successful response wins and the others are cancelled.
+// A hedged attempt is only sent when another grpcConn is free right away,
+// it never waits for a connection. Only idempotent unary RPCs should be
+// hedged, and the reply must be a proto message or Pool.Invoke returns
+// ErrHedgeReply.
+func Hedge(delay time.Duration, attempts int) CallOption {
+	return CallOption{apply: func(co *callOption) {
+		co.hedgeDelay = delay
+		co.hedgeAttempts = attempts
+	}}
+}
+
+// hedgingBudget 对冲请求的令牌桶，每次调用存入 ratio 个令牌，
+// 每次对冲消耗一个令牌，避免过载时对冲请求放大负载
+type hedgingBudget struct {
+	mux    sync.Mutex
+	tokens float64
+	max    float64
+	ratio  float64
+}
+
+func newHedgingBudget(ratio, max float64) *hedgingBudget {
+	return &hedgingBudget{
+		tokens: max,
+		max:    max,
+		ratio:  ratio,
+	}
+}
+
+func (hb *hedgingBudget) deposit() {
+	hb.mux.Lock()
+	hb.tokens += hb.ratio
+	if hb.tokens > hb.max {
+		hb.tokens = hb.max
+	}
+	hb.mux.Unlock()
+}
+
+func (hb *hedgingBudget) withdraw() bool {
+	hb.mux.Lock()
+	defer hb.mux.Unlock()
+
+	if hb.tokens < 1 {
+		return false
+	}
+	hb.tokens--
+	return true
+}
+
+// refund 归还未使用的令牌
+func (hb *hedgingBudget) refund() {
+	hb.mux.Lock()
+	hb.tokens++
+	if hb.tokens > hb.max {
+		hb.tokens = hb.max
+	}
+	hb.mux.Unlock()
+}
+
+type hedgeResult struct {
+	gconn *grpcConn
+	reply proto.Message
+	err   error
+}
+
+func (p *Pool) hedge(ctx context.Context, co *callOption, method string, args interface{}, reply proto.Message, opts []grpc.CallOption) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mux     sync.Mutex
+		used    = make(map[*grpcConn]bool, co.hedgeAttempts)
+		results = make(chan hedgeResult, co.hedgeAttempts)
+	)
+	skip := func(gc *grpcConn) bool {
+		mux.Lock()
+		defer mux.Unlock()
+		return used[gc]
+	}
+
+	// 每次尝试持有各自的逻辑连接，并在不同的 grpcConn 上发送。对冲的尝试
+	// 不等待连接，否则等待期间无法读取先返回的结果
+	getCtx := co.context(ctx)
+	launch := func(nonblocking bool) error {
+		lc, err := p.get(getCtx, skip, nonblocking)
+		if err != nil {
+			return err
+		}
+
+		gconn := lc.(*logicConn).gconn
+		mux.Lock()
+		used[gconn] = true
+		mux.Unlock()
+
+		r := proto.Clone(reply)
+		r.Reset()
+		go func() {
+			err := p.call(lc, func(cc grpc.ClientConnInterface) error {
+				return cc.Invoke(ctx, method, args, r, opts...)
+			})
+			results <- hedgeResult{gconn: gconn, reply: r, err: err}
+		}()
+		return nil
+	}
+
+	p.hedging.deposit()
+	if err := launch(p.opt.Nonblocking); err != nil {
+		return err
+	}
+	launched, inflight := 1, 1
+	// hedgeNext 发送下一次尝试，令牌不足或无法获取连接时返回 false，
+	// 没有发出的对冲不消耗令牌
+	hedgeNext := func() bool {
+		if launched >= co.hedgeAttempts || !p.hedging.withdraw() {
+			return false
+		}
+		if launch(true) != nil {
+			p.hedging.refund()
+			return false
+		}
+		launched++
+		inflight++
+		return true
+	}
+
+	fire := make(chan struct{}, 1)
+	schedule := func() Timer {
+		return p.opt.Clock.AfterFunc(co.hedgeDelay, func() {
+			select {
+			case fire <- struct{}{}:
+			default:
+			}
+		})
+	}
+	timer := schedule()
+	defer func() { timer.Stop() }()
+
+	var lastErr error
+	for inflight > 0 {
+		select {
+		case <-fire:
+			if hedgeNext() && launched < co.hedgeAttempts {
+				timer = schedule()
+			}
+		case res := <-results:
+			inflight--
+			if res.err == nil {
+				reply.Reset()
+				proto.Merge(reply, res.reply)
+				return nil
+			}
+
+			lastErr = res.err
+			if !p.opt.RetryPolicy.retryable(res.err, isConnFailure(res.gconn, res.err), true) {
+				return res.err
+			}
+			if inflight == 0 {
+				hedgeNext()
+			}
+		}
+	}
+	return lastErr
+}
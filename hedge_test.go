@@ -0,0 +1,121 @@
+package grpcpool_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hunyxv/grpcpool"
+	"github.com/hunyxv/grpcpool/grpcpooltest"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestHedge(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	s.SetLatency(50 * time.Millisecond)
+	p := newPool(t, s, grpcpool.WithMaxIdle(2))
+	defer p.Close()
+
+	reply := new(wrapperspb.StringValue)
+	err := p.Invoke(context.Background(), grpcpooltest.EchoMethod, wrapperspb.String("hedged"), reply,
+		grpcpool.Hedge(10*time.Millisecond, 2))
+	if err != nil || reply.GetValue() != "hedged" {
+		t.Fatalf("Invoke = %q, %v", reply.GetValue(), err)
+	}
+	if calls := s.Calls(); calls != 2 {
+		t.Fatalf("server got %d calls, want 2", calls)
+	}
+}
+
+func TestHedgingBudget(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	s.SetLatency(20 * time.Millisecond)
+	p := newPool(t, s, grpcpool.WithMaxIdle(2), grpcpool.WithHedgingBudget(0, 1))
+	defer p.Close()
+
+	for i := 0; i < 3; i++ {
+		err := p.Invoke(context.Background(), grpcpooltest.EchoMethod, wrapperspb.String("x"), new(wrapperspb.StringValue),
+			grpcpool.Hedge(time.Millisecond, 2))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 只有一个令牌，只能对冲一次
+	if calls := s.Calls(); calls != 4 {
+		t.Fatalf("server got %d calls, want 4", calls)
+	}
+}
+
+func TestHedgingBudgetRefund(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	s.SetLatency(20 * time.Millisecond)
+	p := newPool(t, s,
+		grpcpool.WithGrpcPoolSize(1),
+		grpcpool.WithMaxIdle(1),
+		grpcpool.WithMaxStreamsClient(1),
+		grpcpool.WithHedgingBudget(0, 1))
+	defer p.Close()
+
+	invoke := func() {
+		err := p.Invoke(context.Background(), grpcpooltest.EchoMethod, wrapperspb.String("x"), new(wrapperspb.StringValue),
+			grpcpool.Hedge(time.Millisecond, 2))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 没有空闲连接时放弃对冲，令牌留给之后的对冲
+	invoke()
+	if err := p.Resize(2, 2); err != nil {
+		t.Fatal(err)
+	}
+	invoke()
+	if calls := s.Calls(); calls != 3 {
+		t.Fatalf("server got %d calls, want 3", calls)
+	}
+}
+
+func TestHedgeDoesNotWaitForConn(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	s.SetLatency(50 * time.Millisecond)
+	p := newPool(t, s, grpcpool.WithGrpcPoolSize(1), grpcpool.WithMaxIdle(1))
+	defer p.Close()
+
+	// 没有其他连接可以对冲，放弃对冲并读取第一次尝试的结果
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Invoke(context.Background(), grpcpooltest.EchoMethod, wrapperspb.String("x"), new(wrapperspb.StringValue),
+			grpcpool.Hedge(10*time.Millisecond, 2))
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("hedged Invoke is blocked")
+	}
+	if calls := s.Calls(); calls != 1 {
+		t.Fatalf("server got %d calls, want 1", calls)
+	}
+}
+
+func TestHedgeReplyMustBeProto(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	p := newPool(t, s)
+	defer p.Close()
+
+	var reply []byte
+	err := p.Invoke(context.Background(), grpcpooltest.EchoMethod, wrapperspb.String("x"), &reply,
+		grpcpool.Hedge(time.Millisecond, 2))
+	if err != grpcpool.ErrHedgeReply {
+		t.Fatalf("err = %v, want %v", err, grpcpool.ErrHedgeReply)
+	}
+	if calls := s.Calls(); calls != 0 {
+		t.Fatalf("server got %d calls, want 0", calls)
+	}
+}
@@ -0,0 +1,106 @@
+package grpcpool
+
+import (
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxLeaseStackDepth = 32
+
+// Lease 未归还的逻辑连接，见 WithLeakTracking
+type Lease struct {
+	ConnID   int32
+	Acquired time.Time
+	// Stack 获取租约时的调用栈
+	Stack string
+}
+
+type lease struct {
+	connID   int32
+	acquired time.Time
+	pcs      []uintptr
+}
+
+// leaseTracker 记录未归还的租约及其调用栈，调用栈在读取时才格式化
+type leaseTracker struct {
+	mux    sync.Mutex
+	leases map[*logicConn]lease
+}
+
+func newLeaseTracker() *leaseTracker {
+	return &leaseTracker{leases: make(map[*logicConn]lease)}
+}
+
+func (lt *leaseTracker) acquire(lc *logicConn, now time.Time) {
+	pcs := make([]uintptr, maxLeaseStackDepth)
+	// 跳过 runtime.Callers、acquire、Pool.lease、Pool.get 以及 GetContext，
+	// 调用栈从调用方开始。Get 直接调用 Pool.get，与 GetContext 深度相同
+	n := runtime.Callers(5, pcs)
+
+	lt.mux.Lock()
+	lt.leases[lc] = lease{
+		connID:   lc.gconn.id,
+		acquired: now,
+		pcs:      pcs[:n],
+	}
+	lt.mux.Unlock()
+}
+
+func (lt *leaseTracker) release(lc *logicConn) {
+	lt.mux.Lock()
+	delete(lt.leases, lc)
+	lt.mux.Unlock()
+}
+
+func (lt *leaseTracker) outstanding() []Lease {
+	lt.mux.Lock()
+	leases := make([]lease, 0, len(lt.leases))
+	for _, l := range lt.leases {
+		leases = append(leases, l)
+	}
+	lt.mux.Unlock()
+
+	sort.Slice(leases, func(i, j int) bool {
+		return leases[i].acquired.Before(leases[j].acquired)
+	})
+	out := make([]Lease, 0, len(leases))
+	for _, l := range leases {
+		out = append(out, Lease{
+			ConnID:   l.connID,
+			Acquired: l.acquired,
+			Stack:    formatStack(l.pcs),
+		})
+	}
+	return out
+}
+
+func formatStack(pcs []uintptr) string {
+	var sb strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		sb.WriteString(frame.Function)
+		sb.WriteString("\n\t")
+		sb.WriteString(frame.File)
+		sb.WriteByte(':')
+		sb.WriteString(strconv.Itoa(frame.Line))
+		sb.WriteByte('\n')
+		if !more {
+			break
+		}
+	}
+	return sb.String()
+}
+
+// Leases returns the outstanding leases, oldest first. It returns nil
+// unless the pool was created with WithLeakTracking.
+func (p *Pool) Leases() []Lease {
+	if p.leases == nil {
+		return nil
+	}
+	return p.leases.outstanding()
+}
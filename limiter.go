@@ -0,0 +1,192 @@
+package grpcpool
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter adjusts the concurrency limit of a grpcConn according to the
+// observed latency and results of calls made through it.
+type Limiter interface {
+	// Limit returns the current concurrency limit.
+	Limit() int
+
+	// OnSample records a call which took rtt with inflight concurrent calls,
+	// dropped reports whether the call failed because of overload.
+	// It returns the new concurrency limit.
+	OnSample(rtt time.Duration, inflight int, dropped bool) int
+}
+
+// LimiterFactory creates a Limiter for a grpcConn, the limit returned by
+// the Limiter must never exceed max.
+type LimiterFactory func(max int) Limiter
+
+// AIMDConfig AIMD 限制器配置
+type AIMDConfig struct {
+	// Initial 初始并发限制，为 0 时使用最大值
+	Initial int
+
+	// Min 最小并发限制
+	Min int
+
+	// Backoff 过载或超时时限制乘以 Backoff
+	Backoff float64
+
+	// Timeout 调用延迟超过 Timeout 时视为过载
+	Timeout time.Duration
+}
+
+// AIMD returns a LimiterFactory which creates additive-increase /
+// multiplicative-decrease limiters.
+func AIMD(cfg AIMDConfig) LimiterFactory {
+	if cfg.Min <= 0 {
+		cfg.Min = 1
+	}
+	if cfg.Backoff <= 0 || cfg.Backoff >= 1 {
+		cfg.Backoff = 0.9
+	}
+	return func(max int) Limiter {
+		return &aimdLimiter{cfg: cfg, max: max, limit: initialLimit(cfg.Initial, cfg.Min, max)}
+	}
+}
+
+type aimdLimiter struct {
+	mux   sync.Mutex
+	cfg   AIMDConfig
+	max   int
+	limit int
+}
+
+func (l *aimdLimiter) Limit() int {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return l.limit
+}
+
+func (l *aimdLimiter) OnSample(rtt time.Duration, inflight int, dropped bool) int {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if dropped || (l.cfg.Timeout > 0 && rtt > l.cfg.Timeout) {
+		l.limit = clampLimit(int(float64(l.limit)*l.cfg.Backoff), l.cfg.Min, l.max)
+	} else if inflight*2 >= l.limit {
+		// 只有在限制被充分使用时才增加
+		l.limit = clampLimit(l.limit+1, l.cfg.Min, l.max)
+	}
+	return l.limit
+}
+
+// GradientConfig gradient 限制器配置
+type GradientConfig struct {
+	// Initial 初始并发限制，为 0 时使用最大值
+	Initial int
+
+	// Min 最小并发限制
+	Min int
+
+	// Smoothing 新限制的平滑系数，取值 (0, 1]
+	Smoothing float64
+
+	// MinRTTWindow 最小延迟的重新采样周期
+	MinRTTWindow time.Duration
+}
+
+// Gradient returns a LimiterFactory which creates gradient (Vegas style)
+// limiters, the limit grows while the latency stays close to the minimum
+// observed latency and shrinks when requests start queueing.
+func Gradient(cfg GradientConfig) LimiterFactory {
+	if cfg.Min <= 0 {
+		cfg.Min = 1
+	}
+	if cfg.Smoothing <= 0 || cfg.Smoothing > 1 {
+		cfg.Smoothing = 0.2
+	}
+	if cfg.MinRTTWindow <= 0 {
+		cfg.MinRTTWindow = time.Minute
+	}
+	return func(max int) Limiter {
+		limit := initialLimit(cfg.Initial, cfg.Min, max)
+		return &gradientLimiter{cfg: cfg, clock: realClock{}, max: max, limit: float64(limit)}
+	}
+}
+
+// clockSetter 需要计时的限制器，连接池创建限制器后设置 WithClock 的时钟
+type clockSetter interface {
+	setClock(Clock)
+}
+
+type gradientLimiter struct {
+	mux       sync.Mutex
+	cfg       GradientConfig
+	clock     Clock
+	max       int
+	limit     float64
+	minRTT    time.Duration
+	minRTTAt  time.Time
+	smoothRTT float64
+}
+
+func (l *gradientLimiter) setClock(clock Clock) {
+	l.mux.Lock()
+	l.clock = clock
+	l.mux.Unlock()
+}
+
+func (l *gradientLimiter) Limit() int {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return int(l.limit)
+}
+
+func (l *gradientLimiter) OnSample(rtt time.Duration, inflight int, dropped bool) int {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if dropped {
+		l.limit = float64(clampLimit(int(l.limit*0.9), l.cfg.Min, l.max))
+		return int(l.limit)
+	}
+	if rtt <= 0 {
+		return int(l.limit)
+	}
+
+	now := l.clock.Now()
+	if l.minRTT == 0 || rtt < l.minRTT || now.Sub(l.minRTTAt) > l.cfg.MinRTTWindow {
+		l.minRTT = rtt
+		l.minRTTAt = now
+	}
+	if l.smoothRTT == 0 {
+		l.smoothRTT = float64(rtt)
+	} else {
+		l.smoothRTT = l.smoothRTT*0.9 + float64(rtt)*0.1
+	}
+
+	// 未充分使用时不增加限制
+	if float64(inflight)*2 < l.limit {
+		return int(l.limit)
+	}
+
+	gradient := math.Max(0.5, math.Min(1, float64(l.minRTT)/l.smoothRTT))
+	newLimit := l.limit*gradient + math.Sqrt(l.limit)
+	newLimit = l.limit*(1-l.cfg.Smoothing) + newLimit*l.cfg.Smoothing
+	l.limit = math.Max(float64(l.cfg.Min), math.Min(float64(l.max), newLimit))
+	return int(l.limit)
+}
+
+func initialLimit(initial, min, max int) int {
+	if initial <= 0 {
+		initial = max
+	}
+	return clampLimit(initial, min, max)
+}
+
+func clampLimit(limit, min, max int) int {
+	if limit < min {
+		limit = min
+	}
+	if limit > max {
+		limit = max
+	}
+	return limit
+}
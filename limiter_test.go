@@ -0,0 +1,146 @@
+package grpcpool
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAIMDLimiter(t *testing.T) {
+	l := AIMD(AIMDConfig{Initial: 4, Min: 2, Backoff: 0.5, Timeout: 100 * time.Millisecond})(6)
+
+	steps := []struct {
+		rtt      time.Duration
+		inflight int
+		dropped  bool
+		want     int
+	}{
+		{10 * time.Millisecond, 2, false, 5}, // 充分使用时加一
+		{10 * time.Millisecond, 1, false, 5}, // 未充分使用时不变
+		{10 * time.Millisecond, 5, false, 6},
+		{10 * time.Millisecond, 6, false, 6},  // 不超过最大值
+		{200 * time.Millisecond, 6, false, 3}, // 超时减半
+		{10 * time.Millisecond, 3, true, 2},   // 过载减半，不低于最小值
+		{10 * time.Millisecond, 3, true, 2},
+	}
+	if n := l.Limit(); n != 4 {
+		t.Fatalf("initial limit %d, want 4", n)
+	}
+	for i, s := range steps {
+		if n := l.OnSample(s.rtt, s.inflight, s.dropped); n != s.want || l.Limit() != s.want {
+			t.Fatalf("step %d: limit %d, want %d", i, n, s.want)
+		}
+	}
+
+	// 默认从最大值开始，按 0.9 递减
+	l = AIMD(AIMDConfig{})(10)
+	if n := l.Limit(); n != 10 {
+		t.Fatalf("default initial limit %d, want 10", n)
+	}
+	if n := l.OnSample(time.Millisecond, 10, true); n != 9 {
+		t.Fatalf("default backoff: limit %d, want 9", n)
+	}
+}
+
+func TestGradientLimiter(t *testing.T) {
+	l := Gradient(GradientConfig{Initial: 10, Min: 4, Smoothing: 1})(20)
+
+	// 延迟保持在最小延迟时按 sqrt(limit) 增加
+	if n := l.OnSample(10*time.Millisecond, 10, false); n != 13 {
+		t.Fatalf("limit %d after the first sample, want 13", n)
+	}
+	for i := 0; i < 10; i++ {
+		l.OnSample(10*time.Millisecond, 20, false)
+	}
+	if n := l.Limit(); n != 20 {
+		t.Fatalf("limit %d, want max 20", n)
+	}
+
+	// 未充分使用时不变
+	if n := l.OnSample(time.Second, 1, false); n != 20 {
+		t.Fatalf("limit %d after an underused sample, want 20", n)
+	}
+
+	// 排队导致延迟升高时减小，收敛到 limit/2+sqrt(limit) 的不动点 4
+	for i := 0; i < 200; i++ {
+		l.OnSample(40*time.Millisecond, 20, false)
+	}
+	if n := l.Limit(); n != 4 {
+		t.Fatalf("limit %d under queueing, want 4", n)
+	}
+
+	// 过载时减小，不低于最小值
+	if n := l.OnSample(10*time.Millisecond, 4, true); n != 4 {
+		t.Fatalf("limit %d after a drop, want min 4", n)
+	}
+}
+
+func TestAdaptiveCapacity(t *testing.T) {
+	p := newTestPool(t,
+		WithMaxIdle(0),
+		WithMaxStreamsClient(8),
+		WithAdaptiveLimit(AIMD(AIMDConfig{Initial: 4, Backoff: 0.5})),
+	)
+	defer p.Close()
+	gc := newTestGrpcConn(t, p)
+	if n := gc.capacity(); n != 4 {
+		t.Fatalf("initial capacity %d, want 4", n)
+	}
+
+	var leases []*logicConn
+	for i := 0; i < 2; i++ {
+		lc, err := gc.get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		leases = append(leases, lc.(*logicConn))
+	}
+
+	// 限制器的结果作为连接的容量
+	gc.report(time.Millisecond, nil)
+	if n, in := gc.capacity(), gc.inflight(); n != 5 || in != 2 {
+		t.Fatalf("capacity %d with %d in flight, want 5 with 2", n, in)
+	}
+	gc.report(time.Millisecond, status.Error(codes.ResourceExhausted, "overload"))
+	if n, in := gc.capacity(), gc.inflight(); n != 2 || in != 2 {
+		t.Fatalf("capacity %d with %d in flight, want 2 with 2", n, in)
+	}
+	if _, err := gc.get(); err != errGrpcOverload {
+		t.Fatalf("get over the capacity: %v, want %v", err, errGrpcOverload)
+	}
+
+	for _, lc := range leases {
+		gc.recycle(lc)
+	}
+	if !gc.isIdle() {
+		t.Fatalf("current = %d, want capacity %d", atomic.LoadInt32(&gc.current), gc.capacity())
+	}
+}
+
+func TestGradientMinRTTWindow(t *testing.T) {
+	clock := &fixedClock{now: time.Unix(1, 0)}
+	p := newTestPool(t,
+		WithMaxIdle(0),
+		WithClock(clock),
+		WithCleanIntervalTime(time.Hour),
+		WithAdaptiveLimit(Gradient(GradientConfig{Initial: 10, MinRTTWindow: time.Minute})),
+	)
+	defer p.Close()
+	l := newTestGrpcConn(t, p).limiter.(*gradientLimiter)
+
+	l.OnSample(10*time.Millisecond, 0, false)
+	l.OnSample(40*time.Millisecond, 0, false)
+	if l.minRTT != 10*time.Millisecond {
+		t.Fatalf("min rtt %s, want 10ms", l.minRTT)
+	}
+
+	// 超过 MinRTTWindow 后按连接池的时钟重新采样
+	clock.now = clock.now.Add(2 * time.Minute)
+	l.OnSample(40*time.Millisecond, 0, false)
+	if l.minRTT != 40*time.Millisecond {
+		t.Fatalf("min rtt %s after the window, want 40ms", l.minRTT)
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"log"
 	"math"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
 )
 
 const (
@@ -15,6 +17,10 @@ const (
 	defaultMaxIdle           = 3
 	defaultCleanIntervalTime = time.Second
 	defaultClientIdleTimeout = time.Minute
+	defaultMaxAttempts       = 2
+	defaultHedgingRatio      = 0.1
+	defaultHedgingMaxTokens  = 10
+	defaultRotateConcurrency = 1
 )
 
 // Logger is used for logging formatted messages.
@@ -27,6 +33,9 @@ type Logger interface {
 type Option func(*option)
 
 type option struct {
+	// Name identifies the pool in DebugHandler and the admin service
+	Name string
+
 	// Pool size
 	GrpcPoolSize int
 
@@ -46,6 +55,88 @@ type option struct {
 	// ErrPoolOverload will be returned when Pool is exhausted.
 	Nonblocking bool
 
+	// RetryPolicy is used by Pool.Do and Pool.Invoke to retry calls that
+	// failed on a broken connection. By default only idempotent calls are
+	// retried, once.
+	RetryPolicy RetryPolicy
+
+	// CircuitBreaker enables a circuit breaker on every grpcConn, fed by the
+	// results of calls made through Pool.Do and Pool.Invoke.
+	CircuitBreaker *BreakerConfig
+
+	// Limiter adjusts the capacity of every grpcConn adaptively, the
+	// capacity never exceeds MaxStreamsClient.
+	Limiter LimiterFactory
+
+	// StreamLimit discovers the server's SETTINGS_MAX_CONCURRENT_STREAMS,
+	// the capacity of every grpcConn is set from it, capped by MaxStreamLimit.
+	StreamLimit *StreamLimitDetector
+
+	// MaxStreamLimit caps the discovered stream limit, 0 means no cap.
+	MaxStreamLimit int
+
+	// Shards splits the connections across shards, each with its own lock.
+	// Get tries the shard of the current P first and steals from the others.
+	Shards int
+
+	// HedgingRatio is the number of hedging tokens earned by each hedged call,
+	// every hedged attempt costs one token.
+	HedgingRatio float64
+
+	// HedgingMaxTokens is the capacity of the hedging token bucket.
+	HedgingMaxTokens float64
+
+	// MinReady is the number of READY grpcConns the cleaner keeps, it wakes
+	// up IDLE grpcConns and dials new ones until enough are READY.
+	MinReady int
+
+	// RotateConcurrency is the number of grpcConns replaced at once by
+	// Pool.Rotate.
+	RotateConcurrency int
+
+	// CredentialWatcher triggers Pool.Rotate on every receive.
+	CredentialWatcher <-chan struct{}
+
+	// Channelz records the channelz channel ID of every grpcConn.
+	Channelz *ChannelzTracker
+
+	// FaultInjector wraps every leased connection for chaos testing.
+	FaultInjector *FaultInjector
+
+	// MaxInFlight caps the outstanding leases of the pool, 0 means no cap.
+	MaxInFlight int
+
+	// Shed decides what happens to a Get over MaxInFlight.
+	Shed ShedPolicy
+
+	// PriorityReserve is the share of MaxInFlight, or of the stream capacity
+	// of GrpcPoolSize grpcConns, only PriorityHigh leases can use.
+	PriorityReserve float64
+
+	// Tenants partitions the capacity of the pool between tenants.
+	Tenants []Tenant
+
+	// LocalZone is the zone whose grpcConns are preferred, see TargetBuilder.
+	LocalZone string
+
+	// Spillover is the share of the capacity of a local grpcConn over which
+	// leases go to other zones.
+	Spillover float64
+
+	// RateLimit limits every call made through Pool.Do and Pool.Invoke.
+	RateLimit *RateLimit
+
+	// MethodRateLimits limits the calls to each full method name made
+	// through Pool.Invoke, on top of RateLimit.
+	MethodRateLimits map[string]RateLimit
+
+	// LeakTracking records the call stack of every outstanding lease,
+	// see Pool.Leases.
+	LeakTracking bool
+
+	// Clock is the source of time of the pool, the wall clock is used by default.
+	Clock Clock
+
 	// Logger is the customized logger for logging info, if it is not set,
 	// default standard logger from log package is used.
 	Logger Logger
@@ -60,14 +151,46 @@ var defaultOption = option{
 	MaxIdle:           defaultMaxIdle,
 	ClientIdleTimeout: defaultClientIdleTimeout,
 	CleanIntervalTime: defaultCleanIntervalTime,
+	RetryPolicy: RetryPolicy{
+		MaxAttempts:    defaultMaxAttempts,
+		RetryableCodes: []codes.Code{codes.Unavailable},
+		IdempotentOnly: true,
+	},
+	HedgingRatio:      defaultHedgingRatio,
+	HedgingMaxTokens:  defaultHedgingMaxTokens,
+	RotateConcurrency: defaultRotateConcurrency,
+	Clock:             realClock{},
 	Logger:            Logger(log.New(os.Stderr, "", log.LstdFlags)),
 }
 
+// fixedCapacity 连接池的总容量 GrpcPoolSize*MaxStreamsClient 是否固定，
+// 自适应限制和发现的流数限制会随时改变每个连接的容量
+func (opt *option) fixedCapacity() bool {
+	return opt.GrpcPoolSize < defaultGrpcPoolSize && opt.Limiter == nil && opt.StreamLimit == nil
+}
+
+// capacity 连接池的总容量 GrpcPoolSize*MaxStreamsClient
+func (opt *option) capacity() int {
+	capacity := int64(opt.GrpcPoolSize) * int64(opt.MaxStreamsClient)
+	if capacity > math.MaxInt32 {
+		capacity = math.MaxInt32
+	}
+	return int(capacity)
+}
+
 func getDefaultOpt() *option {
 	opt := defaultOption
 	return &opt
 }
 
+// WithName returns a Option which sets the name of the pool, pools are
+// named pool-1, pool-2... by default.
+func WithName(name string) Option {
+	return func(opt *option) {
+		opt.Name = name
+	}
+}
+
 // WithGrpcPoolSize returns a Option which sets the value for pool size
 func WithGrpcPoolSize(size int) Option {
 	return func(opt *option) {
@@ -113,6 +236,196 @@ func WithNonblocking() Option {
 	}
 }
 
+// WithRetryPolicy returns a Option which sets the retry policy used by
+// Pool.Do and Pool.Invoke
+func WithRetryPolicy(rp RetryPolicy) Option {
+	return func(opt *option) {
+		opt.RetryPolicy = rp
+	}
+}
+
+// WithCircuitBreaker returns a Option which enables a circuit breaker on
+// every grpcConn, see DefaultBreakerConfig.
+func WithCircuitBreaker(cfg BreakerConfig) Option {
+	return func(opt *option) {
+		opt.CircuitBreaker = &cfg
+	}
+}
+
+// WithAdaptiveLimit returns a Option which adjusts the capacity of every
+// grpcConn with the Limiter created by f, see AIMD and Gradient.
+func WithAdaptiveLimit(f LimiterFactory) Option {
+	return func(opt *option) {
+		opt.Limiter = f
+	}
+}
+
+// WithStreamLimitDiscovery returns a Option which sets the capacity of every
+// grpcConn from the SETTINGS_MAX_CONCURRENT_STREAMS its server advertised,
+// capped by max, as soon as a SETTINGS frame is received. MaxStreamsClient
+// is used until the limit is known. The pool's Builder must dial with
+// d.DialOption(), d.ContextDialer() or d.TransportCredentials(), called on
+// every dial to track the limit per grpcConn.
+func WithStreamLimitDiscovery(d *StreamLimitDetector, max int) Option {
+	return func(opt *option) {
+		opt.StreamLimit = d
+		opt.MaxStreamLimit = max
+	}
+}
+
+// WithShards returns a Option which splits the connections across n shards
+// to reduce lock contention on machines with many cores.
+func WithShards(n int) Option {
+	return func(opt *option) {
+		opt.Shards = n
+	}
+}
+
+// WithHedgingBudget returns a Option which caps hedged attempts, each hedged
+// call earns ratio tokens up to maxTokens and each extra attempt costs one.
+func WithHedgingBudget(ratio, maxTokens float64) Option {
+	return func(opt *option) {
+		opt.HedgingRatio = ratio
+		opt.HedgingMaxTokens = maxTokens
+	}
+}
+
+// WithMinReady returns a Option which keeps at least n grpcConns READY rather
+// than just present, MaxIdle is raised to n if it is lower. The idle timeout
+// doesn't close READY grpcConns while there are n or fewer. The number of
+// READY grpcConns is exported by the ready_connections gauge, see also
+// Pool.Ready and ReadinessHandler.
+func WithMinReady(n int) Option {
+	return func(opt *option) {
+		opt.MinReady = n
+	}
+}
+
+// WithRotateConcurrency returns a Option which sets the number of grpcConns
+// replaced at once by Pool.Rotate.
+func WithRotateConcurrency(n int) Option {
+	return func(opt *option) {
+		opt.RotateConcurrency = n
+	}
+}
+
+// WithCredentialWatcher returns a Option which rotates every grpcConn of the
+// pool when changed receives, e.g. when the certificates or tokens used by
+// the Builder are renewed. Send on a channel with a buffer of one without
+// blocking to coalesce the changes during a rotation.
+func WithCredentialWatcher(changed <-chan struct{}) Option {
+	return func(opt *option) {
+		opt.CredentialWatcher = changed
+	}
+}
+
+// WithChannelz returns a Option which records the channelz channel ID of every
+// grpcConn in Pool.Stats. The pool's Builder must dial with t.DialOption().
+func WithChannelz(t *ChannelzTracker) Option {
+	return func(opt *option) {
+		opt.Channelz = t
+	}
+}
+
+// WithFaultInjector returns a Option which injects the faults configured in
+// fi into the calls made on leased connections.
+func WithFaultInjector(fi *FaultInjector) Option {
+	return func(opt *option) {
+		opt.FaultInjector = fi
+	}
+}
+
+// WithMaxInFlight returns a Option which caps the outstanding leases of the
+// pool at n, whatever the number of grpcConns, and sheds the Gets over the
+// cap according to policy. Shed Gets fail with an *OverloadError.
+func WithMaxInFlight(n int, policy ShedPolicy) Option {
+	return func(opt *option) {
+		opt.MaxInFlight = n
+		opt.Shed = policy
+	}
+}
+
+// WithPriorityReserve returns a Option which reserves share, between 0 and 1,
+// of the pool capacity for PriorityHigh leases so lower priorities can't
+// starve them. The capacity is MaxInFlight, or GrpcPoolSize times
+// MaxStreamsClient if WithMaxInFlight is not set, in which case Gets over the
+// capacity wait by priority. Without WithMaxInFlight, NewPool returns
+// ErrUnboundedCapacity if WithGrpcPoolSize is not set or the capacity of the
+// grpcConns changes with WithAdaptiveLimit or WithStreamLimitDiscovery. See
+// NewPriorityContext and AtPriority.
+func WithPriorityReserve(share float64) Option {
+	return func(opt *option) {
+		opt.PriorityReserve = share
+	}
+}
+
+// WithTenants returns a Option which admits every lease against the quota of
+// its tenant, see NewTenantContext. The capacity is MaxInFlight, or
+// GrpcPoolSize times MaxStreamsClient if WithMaxInFlight is not set, in which
+// case the same conditions as WithPriorityReserve apply. The guaranteed
+// shares can't add up to more than 1, NewPool returns ErrInvalidTenants
+// otherwise. Use ShedQueue so that the tenants can borrow the guarantees the
+// others don't use, see Tenant.Guaranteed.
+func WithTenants(tenants ...Tenant) Option {
+	return func(opt *option) {
+		opt.Tenants = tenants
+	}
+}
+
+// WithLocalZone returns a Option which prefers the grpcConns whose Locality
+// is in zone. Leases spill over to other zones when no local grpcConn is
+// healthy, and not in TRANSIENT_FAILURE, with less than spillover of its
+// capacity in use. spillover is greater than 0 and at most 1, 1 spills over
+// only when the local capacity is exhausted. Leases on other zones are
+// counted by the cross_zone_leases counter and in Stats. The pool must be
+// created by NewTargetPool, NewPool returns ErrNoLocality otherwise, and
+// ErrInvalidSpillover if spillover is out of range.
+func WithLocalZone(zone string, spillover float64) Option {
+	return func(opt *option) {
+		opt.LocalZone = zone
+		opt.Spillover = spillover
+	}
+}
+
+// WithRateLimit returns a Option which limits the rate of every call made
+// through Pool.Do and Pool.Invoke with a token bucket, the limit can be
+// changed later with Pool.SetRateLimit. The calls are counted by the
+// rate_limited_calls counter.
+func WithRateLimit(limit RateLimit) Option {
+	return func(opt *option) {
+		opt.RateLimit = &limit
+	}
+}
+
+// WithMethodRateLimit returns a Option which limits the rate of the calls to
+// the full method name made through Pool.Invoke, e.g.
+// "/package.Service/Method", on top of WithRateLimit. The limit can be
+// changed later with Pool.SetMethodRateLimit.
+func WithMethodRateLimit(method string, limit RateLimit) Option {
+	return func(opt *option) {
+		if opt.MethodRateLimits == nil {
+			opt.MethodRateLimits = make(map[string]RateLimit)
+		}
+		opt.MethodRateLimits[method] = limit
+	}
+}
+
+// WithLeakTracking returns a Option which records where every outstanding
+// lease was acquired, it costs an allocation and a stack walk per Get.
+func WithLeakTracking() Option {
+	return func(opt *option) {
+		opt.LeakTracking = true
+	}
+}
+
+// WithClock returns a Option which sets the clock driving idle timeouts,
+// cleaning, circuit breakers and hedging of the pool.
+func WithClock(clock Clock) Option {
+	return func(opt *option) {
+		opt.Clock = clock
+	}
+}
+
 // WithLogger returns a Option which sets the value for pool logger
 func WithLogger(logger Logger) Option {
 	return func(opt *option) {
@@ -127,6 +440,9 @@ func WithDebug() Option {
 	}
 }
 
+// metricsNamespace 指标名称的前缀，statistics 与 connection 保持原有名称
+const metricsNamespace = "grpcpool"
+
 var (
 	statistics = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -143,4 +459,48 @@ var (
 		},
 		[]string{"conn"},
 	)
+
+	rateCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "rate_limited_calls",
+			Help:      "Number of calls through the client-side rate limits by result, see WithRateLimit",
+		},
+		[]string{"pool", "method", "result"},
+	)
+
+	crossZoneCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "cross_zone_leases",
+			Help:      "Number of leases on connections outside the local zone, see WithLocalZone",
+		},
+		[]string{"pool", "zone"},
+	)
+
+	readyGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "ready_connections",
+			Help:      "Number of READY connections, see WithMinReady",
+		},
+		[]string{"pool"},
+	)
+)
+
+var (
+	// 预先解析的指标，避免每次 Get/Put 查找标签
+	getCounter = statistics.WithLabelValues("get")
+	putCounter = statistics.WithLabelValues("put")
+	connGauge  = connection.WithLabelValues("conn")
+
+	// 各项功能的指标分别注册，只注册用到的指标
+	debugMetrics, readyMetrics, rateMetrics, zoneMetrics sync.Once
 )
+
+// registerMetrics 注册 collectors，多个连接池只注册一次
+func registerMetrics(once *sync.Once, collectors ...prometheus.Collector) {
+	once.Do(func() {
+		prometheus.MustRegister(collectors...)
+	})
+}
@@ -0,0 +1,320 @@
+package grpcpool
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ShedMode is what Get does once MaxInFlight leases are outstanding.
+type ShedMode int
+
+const (
+	// ShedReject fails Get with an *OverloadError at once.
+	ShedReject ShedMode = iota
+	// ShedQueue makes Get wait for a lease to be returned, up to
+	// ShedPolicy.QueueTimeout, and fails it with an *OverloadError after.
+	// Waiting Gets are admitted by priority, then in arrival order.
+	ShedQueue
+	// ShedPassthrough admits Gets of ShedPolicy.Priority or above over the
+	// limit and fails the others with an *OverloadError at once.
+	ShedPassthrough
+)
+
+func (m ShedMode) String() string {
+	switch m {
+	case ShedReject:
+		return "reject"
+	case ShedQueue:
+		return "queue"
+	case ShedPassthrough:
+		return "passthrough"
+	}
+	return "ShedMode(" + strconv.Itoa(int(m)) + ")"
+}
+
+// ShedPolicy configures load shedding, see WithMaxInFlight.
+type ShedPolicy struct {
+	Mode ShedMode
+
+	// QueueTimeout bounds the wait of ShedQueue, 0 means until ctx is done.
+	QueueTimeout time.Duration
+
+	// Priority is the lowest priority admitted over the limit by
+	// ShedPassthrough, see NewPriorityContext.
+	Priority Priority
+}
+
+// OverloadError is returned by Get, Do and Invoke when a lease is shed, it
+// matches ErrPoolOverload with errors.Is.
+type OverloadError struct {
+	// RetryAfter estimates when a lease will be available, from the average
+	// time leases are held. It is 0 until a lease has been returned.
+	RetryAfter time.Duration
+
+	InFlight int
+	Limit    int
+
+	// Tenant is the tenant of the shed Get if WithTenants is set, InFlight
+	// and Limit are still those of the pool.
+	Tenant string
+}
+
+func (e *OverloadError) Error() string {
+	if e.Tenant != "" {
+		return fmt.Sprintf("%s: tenant %q, %d in flight, limit %d, retry after %s",
+			ErrPoolOverload, e.Tenant, e.InFlight, e.Limit, e.RetryAfter)
+	}
+	return fmt.Sprintf("%s: %d in flight, limit %d, retry after %s",
+		ErrPoolOverload, e.InFlight, e.Limit, e.RetryAfter)
+}
+
+// Is reports whether target is ErrPoolOverload.
+func (e *OverloadError) Is(target error) bool {
+	return target == ErrPoolOverload
+}
+
+// admissionDecay 租约持有时间 EWMA 的权重
+const admissionDecay = 0.2
+
+// admission 连接池级别的在途租约上限，见 WithMaxInFlight、WithPriorityReserve
+// 以及 WithTenants。每次归还名额时重新检查所有排队者，因此排队者都无法立即获准，
+// 新的 Get 可以直接获准时不会越过排队者
+type admission struct {
+	mux      sync.Mutex
+	limit    int
+	reserve  float64 // 为 PriorityHigh 保留的份额
+	reserved int     // 为 PriorityHigh 保留的名额
+	policy   ShedPolicy
+	clock    Clock
+	inflight int
+	waiters  list.List     // 排队中的 *waiter，按优先级从高到低，同优先级先到先得
+	hold     float64       // 租约持有时间的 EWMA（纳秒）
+	closed   chan struct{} // 连接池关闭后关闭
+
+	// 未配置租户时为 nil，键为空的租户用于未标记以及未知的租户
+	tenants map[string]*tenantState
+	quotas  []Tenant // 租户的配额份额，容量变化时重新换算
+}
+
+type waiter struct {
+	pr     Priority
+	tenant *tenantState
+	ready  chan struct{} // 获准后关闭
+}
+
+func newAdmission(limit int, reserve float64, policy ShedPolicy, clock Clock) *admission {
+	return &admission{
+		limit:    limit,
+		reserve:  reserve,
+		reserved: int(reserve * float64(limit)),
+		policy:   policy,
+		clock:    clock,
+		closed:   make(chan struct{}),
+	}
+}
+
+// bound 优先级 pr 可以使用的名额
+func (a *admission) bound(pr Priority) int {
+	if pr >= PriorityHigh {
+		return a.limit
+	}
+	return a.limit - a.reserved
+}
+
+// admissible 优先级 pr 的租户 t 能否获得名额，调用方需持有 a.mux。
+// ShedQueue 之外的模式无法在归还时收回借出的名额，超出保证名额的租约
+// 不能占用其他租户未使用的保证名额
+func (a *admission) admissible(pr Priority, t *tenantState) bool {
+	bound := a.bound(pr)
+	if a.inflight >= bound {
+		return false
+	}
+	if t == nil {
+		return true
+	}
+	if t.inflight >= t.max {
+		return false
+	}
+	return a.policy.Mode == ShedQueue || t.underGuarantee() || a.inflight+a.unused() < bound
+}
+
+// unused 所有租户未使用的保证名额，调用方需持有 a.mux
+func (a *admission) unused() int {
+	var n int
+	for _, t := range a.tenants {
+		if t.underGuarantee() {
+			n += t.guaranteed - t.inflight
+		}
+	}
+	return n
+}
+
+// admit 占用一个名额，调用方需持有 a.mux
+func (a *admission) admit(t *tenantState) {
+	a.inflight++
+	if t != nil {
+		t.inflight++
+	}
+}
+
+// acquire 获取一个在途名额，超出上限时按 policy 排队或拒绝，nonblocking 为 true
+// 时不排队，返回租约所属的租户
+func (a *admission) acquire(ctx context.Context, nonblocking bool) (*tenantState, error) {
+	pr := PriorityFromContext(ctx)
+
+	a.mux.Lock()
+	t := a.tenant(TenantFromContext(ctx))
+	if a.admissible(pr, t) {
+		a.admit(t)
+		a.mux.Unlock()
+		return t, nil
+	}
+	if a.policy.Mode == ShedPassthrough && pr >= a.policy.Priority {
+		a.admit(t)
+		a.mux.Unlock()
+		return t, nil
+	}
+	if a.policy.Mode != ShedQueue || nonblocking {
+		err := a.overload(t)
+		a.mux.Unlock()
+		return nil, err
+	}
+
+	w := &waiter{pr: pr, tenant: t, ready: make(chan struct{})}
+	e := a.enqueue(w)
+	a.mux.Unlock()
+
+	var timeout chan struct{}
+	if a.policy.QueueTimeout > 0 {
+		timeout = make(chan struct{})
+		timer := a.clock.AfterFunc(a.policy.QueueTimeout, func() { close(timeout) })
+		defer timer.Stop()
+	}
+
+	var err error
+	select {
+	case <-w.ready:
+		return t, nil
+	case <-timeout:
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-a.closed:
+		err = ErrPoolClosed
+	}
+
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	// 等待结束的同时可能已经获准
+	select {
+	case <-w.ready:
+		return t, nil
+	default:
+		a.waiters.Remove(e)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, a.overload(t)
+}
+
+// enqueue 将 w 排在优先级不低于它的排队者之后，调用方需持有 a.mux
+func (a *admission) enqueue(w *waiter) *list.Element {
+	for e := a.waiters.Back(); e != nil; e = e.Prev() {
+		if e.Value.(*waiter).pr >= w.pr {
+			return a.waiters.InsertAfter(w, e)
+		}
+	}
+	return a.waiters.PushFront(w)
+}
+
+// done 归还租户 t 的名额并记录租约持有时间
+func (a *admission) done(t *tenantState, hold time.Duration) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	if a.hold == 0 {
+		a.hold = float64(hold)
+	} else {
+		a.hold += admissionDecay * (float64(hold) - a.hold)
+	}
+	a.release(t)
+}
+
+// cancel 归还租户 t 未使用的名额
+func (a *admission) cancel(t *tenantState) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	a.release(t)
+}
+
+// release 归还名额并唤醒可以获准的排队者，调用方需持有 a.mux
+func (a *admission) release(t *tenantState) {
+	a.inflight--
+	if t != nil {
+		t.inflight--
+	}
+
+	a.wake()
+}
+
+// resize 按新的容量换算保留名额与租户配额，并唤醒可以获准的排队者
+func (a *admission) resize(limit int) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	a.limit = limit
+	a.reserved = int(a.reserve * float64(limit))
+	if a.tenants != nil {
+		a.setTenants(a.quotas)
+	}
+	a.wake()
+}
+
+// wake 按顺序唤醒可以获准的排队者，调用方需持有 a.mux
+func (a *admission) wake() {
+	// 先唤醒未用满保证名额的租户，借用的名额归还后由它们收回；
+	// 受租户配额限制的排队者不阻塞其他租户
+	for _, guaranteed := range [...]bool{true, false} {
+		for e := a.waiters.Front(); e != nil && a.inflight < a.limit; {
+			next := e.Next()
+			w := e.Value.(*waiter)
+			if (!guaranteed || w.tenant.underGuarantee()) && a.admissible(w.pr, w.tenant) {
+				a.waiters.Remove(e)
+				a.admit(w.tenant)
+				close(w.ready)
+			}
+			e = next
+		}
+	}
+}
+
+// overload 生成拒绝租户 t 的错误，名额按 limit/hold 的速率归还，
+// 排在所有排队者之后需要等待 (queued+1)*hold/limit，调用方需持有 a.mux
+func (a *admission) overload(t *tenantState) error {
+	queued := a.waiters.Len()
+	err := &OverloadError{
+		RetryAfter: time.Duration(a.hold * float64(queued+1) / float64(a.limit)),
+		InFlight:   a.inflight,
+		Limit:      a.limit,
+	}
+	if t != nil {
+		t.rejected++
+		err.Tenant = t.name
+	}
+	return err
+}
+
+// counts 返回在途与排队的租约数以及容量
+func (a *admission) counts() (inflight, queued, limit int) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	return a.inflight, a.waiters.Len(), a.limit
+}
+
+func (a *admission) close() {
+	close(a.closed)
+}
@@ -0,0 +1,126 @@
+package grpcpool_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hunyxv/grpcpool"
+	"github.com/hunyxv/grpcpool/grpcpooltest"
+)
+
+func overloadError(t *testing.T, err error) *grpcpool.OverloadError {
+	t.Helper()
+	var oe *grpcpool.OverloadError
+	if !errors.Is(err, grpcpool.ErrPoolOverload) || !errors.As(err, &oe) {
+		t.Fatalf("err = %v, want an OverloadError", err)
+	}
+	return oe
+}
+
+func TestMaxInFlightReject(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	clock := grpcpooltest.NewFakeClock(time.Now())
+	p := newPool(t, s,
+		grpcpool.WithMaxStreamsClient(1),
+		grpcpool.WithClock(clock),
+		grpcpool.WithMaxInFlight(2, grpcpool.ShedPolicy{Mode: grpcpool.ShedReject}))
+	defer p.Close()
+
+	lc1, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(100 * time.Millisecond)
+	p.Put(lc1)
+
+	lc1, _ = p.Get()
+	lc2, _ := p.Get()
+	oe := overloadError(t, func() error { _, err := p.Get(); return err }())
+	if oe.InFlight != 2 || oe.Limit != 2 || oe.RetryAfter != 50*time.Millisecond {
+		t.Fatalf("unexpected error: %+v", oe)
+	}
+	if st := p.Stats(); st.InFlight != 2 || st.MaxInFlight != 2 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+
+	p.Put(lc1)
+	lc1, err = p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Put(lc1)
+	p.Put(lc2)
+}
+
+func TestMaxInFlightQueue(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	p := newPool(t, s, grpcpool.WithMaxInFlight(1, grpcpool.ShedPolicy{
+		Mode:         grpcpool.ShedQueue,
+		QueueTimeout: 20 * time.Millisecond,
+	}))
+	defer p.Close()
+
+	lc, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	overloadError(t, func() error { _, err := p.Get(); return err }())
+
+	got := make(chan error, 1)
+	go func() {
+		lc, err := p.GetContext(context.Background())
+		if err == nil {
+			p.Put(lc)
+		}
+		got <- err
+	}()
+	waitFor(t, func() bool { return p.Stats().Queued == 1 })
+	p.Put(lc)
+	if err := <-got; err != nil {
+		t.Fatal(err)
+	}
+
+	// 关闭连接池时唤醒排队者
+	lc, err = p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_, err := p.Get()
+		got <- err
+	}()
+	waitFor(t, func() bool { return p.Stats().Queued == 1 })
+	p.Close()
+	if err := <-got; err != grpcpool.ErrPoolClosed {
+		t.Fatalf("err = %v, want ErrPoolClosed", err)
+	}
+}
+
+func TestMaxInFlightPassthrough(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	p := newPool(t, s, grpcpool.WithMaxInFlight(1, grpcpool.ShedPolicy{
+		Mode:     grpcpool.ShedPassthrough,
+		Priority: grpcpool.PriorityHigh,
+	}))
+	defer p.Close()
+
+	if _, err := p.Get(); err != nil {
+		t.Fatal(err)
+	}
+	overloadError(t, func() error { _, err := p.Get(); return err }())
+
+	ctx := grpcpool.NewPriorityContext(context.Background(), grpcpool.PriorityHigh)
+	for i := 0; i < 3; i++ {
+		if _, err := p.GetContext(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := p.Stats().InFlight; n != 4 {
+		t.Fatalf("%d in flight, want 4", n)
+	}
+}
@@ -1,18 +1,19 @@
 package grpcpool
 
 import (
+	"context"
 	"errors"
 	"math"
 	"math/rand"
-	"runtime"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/hunyxv/grpcpool/internal"
-	"github.com/prometheus/client_golang/prometheus"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 )
 
 var (
@@ -22,6 +23,37 @@ var (
 	// ErrConnClosed grpc 连接已关闭
 	ErrConnClosed = errors.New("the grpc connection has closed")
 
+	// ErrCircuitOpen 连接池中所有连接均已熔断
+	ErrCircuitOpen = errors.New("all grpc connections are circuit broken")
+
+	// ErrConnNotFound 连接池中没有指定 id 的连接
+	ErrConnNotFound = errors.New("grpc connection not found")
+
+	// ErrNotReady 新建的连接在限定时间内没有进入 READY 状态
+	ErrNotReady = errors.New("grpc connection not ready")
+
+	// ErrInvalidSize Resize 参数不合法
+	ErrInvalidSize = errors.New("invalid pool size")
+
+	// ErrInvalidTenants 租户配额不合法，见 WithTenants
+	ErrInvalidTenants = errors.New("invalid tenant quotas")
+
+	// ErrUnboundedCapacity 连接池没有固定的总容量，需要设置 WithMaxInFlight，
+	// 见 WithPriorityReserve 以及 WithTenants
+	ErrUnboundedCapacity = errors.New("grpc pool: capacity is unbounded or adaptive, set WithMaxInFlight")
+
+	// ErrNoLocality 连接池没有连接的 Locality，WithLocalZone 需要使用 NewTargetPool
+	ErrNoLocality = errors.New("grpc pool: WithLocalZone needs a pool created by NewTargetPool")
+
+	// ErrInvalidSpillover WithLocalZone 的溢出阈值不在 (0, 1] 范围内
+	ErrInvalidSpillover = errors.New("grpc pool: spillover must be greater than 0 and at most 1")
+
+	// ErrHedgeReply 对冲调用的 reply 不是 proto.Message，见 Hedge
+	ErrHedgeReply = errors.New("grpc pool: hedged reply must be a proto.Message")
+
+	// ErrRateLimited 调用超出客户端限流，见 WithRateLimit
+	ErrRateLimited = errors.New("grpc pool: rate limit exceeded")
+
 	// ErrPoolOverload 连接池资源已满载
 	ErrPoolOverload = errors.New("pool overload")
 
@@ -37,17 +69,43 @@ const (
 	CLOSED
 )
 
+// poolSeq 未命名连接池的序号
+var poolSeq int32
+
 // Builder 创建conn的构造函数
 type Builder func() (*grpc.ClientConn, error)
 
 // Pool grpc 连接池
 type Pool struct {
+	crossZone    uint64   // 跨可用区的租约数，放在首位保证 64 位对齐
+	zoneCounters sync.Map // 可用区 -> crossZoneCounter 的计数器，关闭时删除
+
+	name    string
 	state   int32
 	mux     *sync.RWMutex
 	cond    *sync.Cond
 	opt     *option
 	conns   []*grpcConn
-	builder Builder
+	builder TargetBuilder
+
+	buildMux sync.Mutex // 开启 WithChannelz 或 WithStreamLimitDiscovery 时串行化 Builder 调用
+
+	hedging *hedgingBudget
+	events  *eventLog
+	leases  *leaseTracker // 未开启 WithLeakTracking 时为 nil
+
+	admission *admission // 未开启 WithMaxInFlight 时为 nil
+	rates     rateLimits
+
+	// 连接池满载时 lease 等待连接归还，见 waitRelease
+	waiters  int32
+	relMux   sync.Mutex
+	released chan struct{}
+
+	// 分片模式，见 WithShards
+	shards    []*shard
+	shardHint sync.Pool
+	size      int32 // len(conns)
 
 	r  *rand.Rand
 	ch chan struct{}
@@ -55,97 +113,294 @@ type Pool struct {
 }
 
 // NewPool create a grpc pool
-func NewPool(builder Builder, opts ...Option) (pool *Pool, err error) {
+func NewPool(builder Builder, opts ...Option) (*Pool, error) {
+	return newPool(builder.target(), false, opts...)
+}
+
+// newPool 创建连接池，locality 表示 builder 是否提供连接的 Locality
+func newPool(builder TargetBuilder, locality bool, opts ...Option) (pool *Pool, err error) {
 	opt := getDefaultOpt()
 	for _, f := range opts {
 		f(opt)
 	}
+	if opt.MaxIdle > opt.GrpcPoolSize {
+		opt.MaxIdle = opt.GrpcPoolSize
+	}
+	if opt.MinReady > opt.GrpcPoolSize {
+		opt.MinReady = opt.GrpcPoolSize
+	}
+	if opt.MaxIdle < opt.MinReady {
+		opt.MaxIdle = opt.MinReady
+	}
+	if opt.RotateConcurrency < 1 {
+		opt.RotateConcurrency = 1
+	}
+	if !validTenants(opt.Tenants) {
+		return nil, ErrInvalidTenants
+	}
+	if (opt.PriorityReserve > 0 || opt.Tenants != nil) && opt.MaxInFlight <= 0 && !opt.fixedCapacity() {
+		return nil, ErrUnboundedCapacity
+	}
+	if opt.LocalZone != "" {
+		if !locality {
+			return nil, ErrNoLocality
+		}
+		if opt.Spillover <= 0 || opt.Spillover > 1 {
+			return nil, ErrInvalidSpillover
+		}
+	}
 
 	if opt.Debug {
-		prometheus.MustRegister(statistics)
-		//prometheus.MustRegister(statistics_put)
-		prometheus.MustRegister(connection)
+		registerMetrics(&debugMetrics, statistics, connection)
+	}
+	if opt.MinReady > 0 {
+		registerMetrics(&readyMetrics, readyGauge)
+	}
+	if opt.LocalZone != "" {
+		registerMetrics(&zoneMetrics, crossZoneCounter)
+	}
+
+	if opt.Name == "" {
+		opt.Name = "pool-" + strconv.Itoa(int(atomic.AddInt32(&poolSeq, 1)))
 	}
 
 	pool = &Pool{
+		name:    opt.Name,
 		mux:     new(sync.RWMutex),
 		builder: builder,
 		cond:    sync.NewCond(internal.NewSpinLock()),
 		conns:   make([]*grpcConn, 0, opt.MaxIdle),
 		opt:     opt,
-		r:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		hedging: newHedgingBudget(opt.HedgingRatio, opt.HedgingMaxTokens),
+		events:  newEventLog(defaultEventLogSize),
+		r:       rand.New(rand.NewSource(opt.Clock.Now().UnixNano())),
 		ch:      make(chan struct{}, 0),
 	}
 
+	if opt.Shards > 1 {
+		pool.initShards(opt.Shards)
+	}
+	if opt.LeakTracking {
+		pool.leases = newLeaseTracker()
+	}
+	if opt.MaxInFlight > 0 {
+		pool.admission = newAdmission(opt.MaxInFlight, opt.PriorityReserve, opt.Shed, opt.Clock)
+	} else if opt.PriorityReserve > 0 || opt.Tenants != nil {
+		// 没有设置 MaxInFlight 时按连接池的总容量排队，与原先 Get 阻塞的行为一致
+		pool.admission = newAdmission(opt.capacity(), opt.PriorityReserve, ShedPolicy{Mode: ShedQueue}, opt.Clock)
+	}
+	if pool.admission != nil && opt.Tenants != nil {
+		pool.admission.setTenants(opt.Tenants)
+	}
+
 	for i := 0; i < pool.opt.MaxIdle; i++ {
-		conn, err := pool.builder()
+		gconn, err := pool.build()
 		if err != nil {
 			return nil, err
 		}
-
-		gconn := newGrpcConn(pool, conn)
 		pool.conns = append(pool.conns, gconn)
-		if opt.Debug {
-			connection.WithLabelValues("conn").Add(1)
-		}
+	}
+	pool.reshard()
+
+	if opt.RateLimit != nil {
+		pool.SetRateLimit(*opt.RateLimit)
+	}
+	for method, limit := range opt.MethodRateLimits {
+		pool.SetMethodRateLimit(method, limit)
 	}
 
 	go pool.cleanPeriodically()
+	if opt.CredentialWatcher != nil {
+		go pool.watchCredentials(opt.CredentialWatcher)
+	}
 	return
 }
 
+// Name returns the name of the pool, see WithName.
+func (p *Pool) Name() string {
+	return p.name
+}
+
 // Get get a grpc logic connection
-func (p *Pool) Get() (logicconn LogicConn, err error) {
-	if atomic.LoadInt32(&p.state) == CLOSED {
-		return nil, ErrPoolClosed
+func (p *Pool) Get() (LogicConn, error) {
+	return p.get(context.Background(), nil, p.opt.Nonblocking)
+}
+
+// GetContext get a grpc logic connection, ctx.Err() will be returned
+// when ctx is done before a connection is available.
+func (p *Pool) GetContext(ctx context.Context) (LogicConn, error) {
+	return p.get(ctx, nil, p.opt.Nonblocking)
+}
+
+// get 获取逻辑连接，skip 返回 true 的 grpcConn 不参与选取，
+// nonblocking 为 true 时不排队也不等待连接归还，直接返回 ErrPoolOverload
+func (p *Pool) get(ctx context.Context, skip func(*grpcConn) bool, nonblocking bool) (LogicConn, error) {
+	if p.admission == nil {
+		return p.lease(ctx, skip, nonblocking)
 	}
 
-	p.mux.RLock()
+	tenant, err := p.admission.acquire(ctx, nonblocking)
+	if err != nil {
+		return nil, err
+	}
+	lc, err := p.lease(ctx, skip, nonblocking)
+	if err != nil {
+		p.admission.cancel(tenant)
+		return nil, err
+	}
+	logicconn := lc.(*logicConn)
+	logicconn.admitted = p.opt.Clock.Now().UnixNano()
+	logicconn.tenant = tenant
+	return lc, nil
+}
 
-	l := len(p.conns)
-	if l <= p.opt.MaxIdle {
-		logicconn, err = p.conns[time.Now().UnixNano()%int64(l)].get()
-		p.mux.RUnlock()
-		if err == errGrpcOverload {
-			err = p.createNewGrpcConn(l)
-			if err != nil {
-				return
-			}
-			runtime.Gosched()
-			return p.Get()
+// lease 从连接池中获取一个逻辑连接，没有可用连接时新建 grpcConn，
+// 连接池满载时等待连接归还，nonblocking 为 true 时返回 ErrPoolOverload
+func (p *Pool) lease(ctx context.Context, skip func(*grpcConn) bool, nonblocking bool) (LogicConn, error) {
+	var released <-chan struct{}
+	for {
+		if atomic.LoadInt32(&p.state) == CLOSED {
+			return nil, ErrPoolClosed
 		}
-		if p.opt.Debug {
-			statistics.WithLabelValues("get").Add(1)
+		if err := ctx.Err(); err != nil {
+			return nil, err
 		}
-		return logicconn, nil
-	}
 
-	l = int(math.Round(float64(l) * 0.8))
-	index := time.Now().UnixNano() % int64(l)
-	logicconn, err = p.conns[index].get()
-	if err == ErrConnClosed || err == errGrpcOverload {
-		for i := int(index) + 1; i < len(p.conns); i++ {
-			logicconn, err = p.conns[i].get()
-			if err != nil {
-				continue
+		var (
+			logicconn LogicConn
+			l         int
+			err       error
+		)
+		if p.opt.LocalZone != "" {
+			logicconn, l, err = p.pickLocal(skip)
+		} else {
+			logicconn, l, err = p.pickConn(skip)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if logicconn != nil {
+			if p.opt.LocalZone != "" {
+				p.countZone(logicconn)
+			}
+			if p.opt.Debug {
+				getCounter.Inc()
 			}
+			if p.leases != nil {
+				p.leases.acquire(logicconn.(*logicConn), p.opt.Clock.Now())
+			}
+			return logicconn, nil
 		}
-		if logicconn == nil {
-			ll := len(p.conns)
-			p.mux.RUnlock()
-			err = p.createNewGrpcConn(ll)
-			if err != nil {
-				return
+
+		if l < p.poolSize() {
+			if err := p.createNewGrpcConn(l); err != nil {
+				return nil, err
 			}
-			runtime.Gosched()
-			return p.Get()
+			continue
+		}
+		if nonblocking {
+			return nil, ErrPoolOverload
+		}
+		// 先登记再重新选取一次，避免错过两次选取之间的归还
+		if released == nil {
+			released = p.waitRelease()
+			continue
+		}
+		select {
+		case <-released:
+			released = nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-p.ch:
+			return nil, ErrPoolClosed
 		}
 	}
-	p.mux.RUnlock()
-	if p.opt.Debug {
-		statistics.WithLabelValues("get").Add(1)
+}
+
+// waitRelease 登记一个等待者，返回的 channel 在下次归还连接或连接列表变化时关闭
+func (p *Pool) waitRelease() <-chan struct{} {
+	p.relMux.Lock()
+	defer p.relMux.Unlock()
+
+	atomic.AddInt32(&p.waiters, 1)
+	if p.released == nil {
+		p.released = make(chan struct{})
 	}
-	return
+	return p.released
+}
+
+// notifyRelease 唤醒所有等待的 lease
+func (p *Pool) notifyRelease() {
+	if atomic.LoadInt32(&p.waiters) == 0 {
+		return
+	}
+
+	p.relMux.Lock()
+	defer p.relMux.Unlock()
+
+	atomic.StoreInt32(&p.waiters, 0)
+	if p.released != nil {
+		close(p.released)
+		p.released = nil
+	}
+}
+
+// poolSize 返回连接池的最大连接数，GrpcPoolSize 可能被 Resize 修改
+func (p *Pool) poolSize() int {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+	return p.opt.GrpcPoolSize
+}
+
+// pickConn 按是否分片选取 grpcConn
+func (p *Pool) pickConn(skip func(*grpcConn) bool) (LogicConn, int, error) {
+	if p.shards != nil {
+		return p.pickSharded(skip)
+	}
+	return p.pick(skip)
+}
+
+// pick 选取一个可用的 grpcConn，没有可用连接时返回 nil 以及当前连接数，
+// 所有连接均已熔断时返回 ErrCircuitOpen
+func (p *Pool) pick(skip func(*grpcConn) bool) (LogicConn, int, error) {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+
+	l := len(p.conns)
+	if l == 0 {
+		return nil, l, nil
+	}
+
+	n := l
+	if l > p.opt.MaxIdle {
+		n = int(math.Round(float64(l) * 0.8))
+	}
+	// 起点落在前 80% 的连接上，从起点循环扫描所有连接
+	index := int(p.opt.Clock.Now().UnixNano() % int64(n))
+	for i := 0; i < l; i++ {
+		gc := p.conns[(index+i)%l]
+		if skip != nil && skip(gc) {
+			continue
+		}
+		if logicconn, err := gc.take(); err == nil {
+			return logicconn, l, nil
+		}
+	}
+
+	if p.opt.CircuitBreaker != nil && p.allBroken() {
+		return nil, l, ErrCircuitOpen
+	}
+	return nil, l, nil
+}
+
+// allBroken 所有连接是否都已熔断，调用方需持有 p.mux
+func (p *Pool) allBroken() bool {
+	for _, gc := range p.conns {
+		if !gc.isBroken() {
+			return false
+		}
+	}
+	return true
 }
 
 // Put release grpc logic connection
@@ -154,79 +409,146 @@ func (p *Pool) Put(lc LogicConn) {
 		return
 	}
 
-	logicconn := lc.(logicConn)
+	logicconn := lc.(*logicConn)
+	if p.leases != nil {
+		p.leases.release(logicconn)
+	}
+	if p.admission != nil {
+		p.admission.done(logicconn.tenant, time.Duration(p.opt.Clock.Now().UnixNano()-logicconn.admitted))
+	}
 	grpcconn := logicconn.gconn
 	grpcconn.recycle(logicconn)
+	p.notifyRelease()
 	if p.opt.Debug {
-		statistics.WithLabelValues("put").Add(1)
+		putCounter.Inc()
 	}
 }
 
 func (p *Pool) cleanPeriodically() {
-	heartbeat := time.NewTicker(p.opt.CleanIntervalTime)
+	heartbeat := p.opt.Clock.NewTicker(p.opt.CleanIntervalTime)
 	defer heartbeat.Stop()
 
 	for {
 		select {
-		case <-heartbeat.C:
-			p.mux.Lock()
-			for i := len(p.conns); i < p.opt.MaxIdle; i++ {
-				conn, err := p.builder()
-				if err != nil {
-					panic(err.Error())
-				}
-
-				gconn := newGrpcConn(p, conn)
-				p.conns = append(p.conns, gconn)
+		case <-heartbeat.C():
+			p.clean()
+		case <-p.ch:
+			return
+		}
+	}
+}
+
+// clean 关闭失效、超时以及多余的空闲连接，然后将连接数补充到 MaxIdle
+func (p *Pool) clean() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if atomic.LoadInt32(&p.state) == CLOSED {
+		return
+	}
+
+	var ready int
+	if p.opt.MinReady > 0 {
+		ready = p.readyConns()
+	}
+	for i := 0; i < len(p.conns); {
+		gc := p.conns[i]
+		reason := gc.evictReason()
+		if reason == idleTimeoutReason && p.opt.MinReady > 0 && gc.countsReady() && ready <= p.opt.MinReady {
+			// 关闭后 READY 的连接会少于 MinReady，保留空闲的连接
+			reason = ""
+		}
+		if reason != "" {
+			if gc.countsReady() {
+				ready--
 			}
+			p.removeConn(i, reason)
+			continue
+		}
+
+		if p.opt.StreamLimit != nil {
+			gc.refreshCapacity()
+		}
+		i++
+	}
+	p.trimIdle()
+
+	for i := len(p.conns); i < p.opt.MaxIdle; i++ {
+		gconn, err := p.build()
+		if err != nil {
+			p.opt.Logger.Printf("warning: %s\n", err.Error())
+			break
+		}
+		p.conns = append(p.conns, gconn)
+	}
+
+	if p.opt.MinReady > 0 {
+		p.ensureReady()
+	}
 
-			var idleCount int
-			l := len(p.conns)
-			for i := 0; i < l; {
-				if p.conns[i].isClosed() || p.conns[i].isTimeout() {
-					grpcconn := p.conns[i]
-					if err := grpcconn.close(); err != nil {
-						p.opt.Logger.Printf("warning: %s\n", err.Error())
-					}
-					copy(p.conns[i:], p.conns[i+1:])
-					p.conns[l-1] = nil
-					p.conns = p.conns[:l-1]
-					l--
-					continue
-				}
-
-				if p.conns[i].isIdle() {
-					idleCount++
-					if idleCount > p.opt.MaxIdle {
-						grpcconn := p.conns[i]
-						if err := grpcconn.close(); err != nil {
-							p.opt.Logger.Printf("warning: %s\n", err.Error())
-						}
-						copy(p.conns[i:], p.conns[i+1:])
-						p.conns[l-1] = nil
-						p.conns = p.conns[:l-1]
-						l--
-						if p.opt.Debug {
-							connection.WithLabelValues("conn").Sub(1)
-						}
-						continue
-					}
-				}
-				i++
+	p.reshard()
+	p.opt.Logger.Printf("conn: %d", len(p.conns))
+}
+
+// trimIdle 从后往前关闭超过 MaxIdle 的空闲连接，开启 WithMinReady 时
+// 依次关闭连接失败的、尚未 READY 的以及 READY 的连接，避免关闭刚补充
+// 正在建立连接的连接，调用方需持有 p.mux 写锁
+func (p *Pool) trimIdle() {
+	var idle int
+	for _, gc := range p.conns {
+		if gc.isIdle() {
+			idle++
+		}
+	}
+
+	excess := idle - p.opt.MaxIdle
+	pass := 2
+	if p.opt.MinReady > 0 {
+		pass = 0
+	}
+	for ; pass < 3 && excess > 0; pass++ {
+		for i := len(p.conns) - 1; i >= 0 && excess > 0; i-- {
+			gc := p.conns[i]
+			if !gc.isIdle() {
+				continue
 			}
-			p.opt.Logger.Printf("conn: %d", len(p.conns))
-			p.mux.Unlock()
-		case <-p.ch:
-			return
+			if pass == 0 && gc.conn.GetState() != connectivity.TransientFailure {
+				continue
+			}
+			if pass == 1 && gc.isReady() {
+				continue
+			}
+			p.removeConn(i, "idle trim")
+			excess--
 		}
 	}
 }
 
+// removeConn 关闭并移除 p.conns[i]，调用方需持有 p.mux 写锁
+func (p *Pool) removeConn(i int, reason string) {
+	p.event(EventConnClosed, p.conns[i].id, reason)
+	if err := p.conns[i].close(); err != nil {
+		p.opt.Logger.Printf("warning: %s\n", err.Error())
+	}
+
+	l := len(p.conns)
+	copy(p.conns[i:], p.conns[i+1:])
+	p.conns[l-1] = nil
+	p.conns = p.conns[:l-1]
+	if p.opt.Debug {
+		connGauge.Dec()
+	}
+}
+
 // Close close pool
 func (p *Pool) Close() {
 	p.mux.Lock()
 	defer p.mux.Unlock()
 
+	if atomic.LoadInt32(&p.state) == CLOSED {
+		return
+	}
+
 	close(p.ch)
 	conns := p.conns
 	for _, conn := range conns {
@@ -237,31 +559,85 @@ func (p *Pool) Close() {
 	}
 
 	p.conns = p.conns[:0]
-	p.state = CLOSED
+	p.reshard()
+	atomic.StoreInt32(&p.state, CLOSED)
+	if p.admission != nil {
+		p.admission.close()
+	}
+	p.event(EventPoolClosed, 0, "")
+	if p.opt.MinReady > 0 {
+		readyGauge.DeleteLabelValues(p.name)
+	}
+	p.deleteRateMetrics()
+	p.deleteZoneMetrics()
+	p.events.closeSubscribers()
 	return
 }
 
-func (p *Pool) createNewGrpcConn(l int) (err error) {
-	if l != len(p.conns) {
-		return
+// streamLimit 按服务端通告的流数限制 limit 计算 grpcConn 的最大容量
+func (p *Pool) streamLimit(limit int) int {
+	if limit <= 0 {
+		return p.opt.MaxStreamsClient
+	}
+	if p.opt.MaxStreamLimit > 0 && limit > p.opt.MaxStreamLimit {
+		limit = p.opt.MaxStreamLimit
 	}
+	return limit
+}
 
+func (p *Pool) createNewGrpcConn(l int) (err error) {
 	p.mux.Lock()
 	defer p.mux.Unlock()
 
-	if l != len(p.conns) || len(p.conns) > p.opt.GrpcPoolSize {
+	if atomic.LoadInt32(&p.state) == CLOSED {
+		return ErrPoolClosed
+	}
+	if l != len(p.conns) || len(p.conns) >= p.opt.GrpcPoolSize {
 		return
 	}
 
-	clientConn, err := p.builder()
+	gconn, err := p.build()
 	if err != nil {
 		return
 	}
+	p.conns = append(p.conns, gconn)
+	p.reshard()
+	return
+}
+
+// build 通过 Builder 创建新的 grpcConn
+func (p *Pool) build() (*grpcConn, error) {
+	tracker, detector := p.opt.Channelz, p.opt.StreamLimit
+	if tracker != nil || detector != nil {
+		// Rotate 会并发创建连接，串行化以便将 token 对应到连接
+		p.buildMux.Lock()
+		defer p.buildMux.Unlock()
+		// 丢弃 Builder 之外生成的 token
+		if tracker != nil {
+			tracker.take()
+		}
+		if detector != nil {
+			detector.take()
+		}
+	}
+
+	conn, locality, err := p.builder()
+	if err != nil {
+		return nil, err
+	}
+	var streams *connStreamLimit
+	if detector != nil {
+		streams = detector.take()
+	}
+	gconn := newGrpcConn(p, conn, streams)
+	gconn.locality = locality
+	if tracker != nil {
+		gconn.channelzToken = tracker.take()
+	}
 	if p.opt.Debug {
-		connection.WithLabelValues("conn").Add(1)
+		connGauge.Inc()
 	}
-	p.conns = append(p.conns, newGrpcConn(p, clientConn))
-	return
+	return gconn, nil
 }
 
 type noCopy struct{}
@@ -0,0 +1,385 @@
+package grpcpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// offlineBuilder 创建一个永远连不上的连接，Get/Put 不依赖连接状态
+func offlineBuilder() (*grpc.ClientConn, error) {
+	return grpc.Dial("passthrough:///offline",
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return nil, errors.New("offline")
+		}),
+	)
+}
+
+func newTestPool(tb testing.TB, opts ...Option) *Pool {
+	opts = append([]Option{WithLogger(log.New(ioutil.Discard, "", 0))}, opts...)
+	p, err := NewPool(offlineBuilder, opts...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return p
+}
+
+// checkInvariants 检查连接池的计数不变式
+func checkInvariants(p *Pool) error {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+
+	if len(p.conns) > p.opt.GrpcPoolSize {
+		return fmt.Errorf("%d conns exceed pool size %d", len(p.conns), p.opt.GrpcPoolSize)
+	}
+	for _, gc := range p.conns {
+		current := atomic.LoadInt32(&gc.current)
+		if current < 0 || int(current) > p.opt.MaxStreamsClient {
+			return fmt.Errorf("conn %d: current %d out of [0, %d]", gc.id, current, p.opt.MaxStreamsClient)
+		}
+	}
+	return nil
+}
+
+func idleConns(p *Pool) int {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+
+	var n int
+	for _, gc := range p.conns {
+		if gc.isIdle() {
+			n++
+		}
+	}
+	return n
+}
+
+// TestPoolInvariantsQuick 随机的 Get/Put/clean 序列下不变式始终成立
+func TestPoolInvariantsQuick(t *testing.T) {
+	f := func(ops []byte, maxStreams, poolSize, maxIdle uint8) bool {
+		opts := []Option{
+			WithMaxStreamsClient(int(maxStreams%8) + 1),
+			WithGrpcPoolSize(int(poolSize%8) + 1),
+			WithMaxIdle(int(maxIdle % 4)),
+			WithCleanIntervalTime(time.Hour),
+		}
+		p := newTestPool(t, opts...)
+		defer p.Close()
+
+		var leases []LogicConn
+		for _, op := range ops {
+			switch op % 3 {
+			case 0:
+				ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+				lc, err := p.GetContext(ctx)
+				cancel()
+				if err == nil {
+					leases = append(leases, lc)
+				} else if err != context.DeadlineExceeded {
+					t.Log(err)
+					return false
+				}
+			case 1:
+				if len(leases) > 0 {
+					i := int(op) % len(leases)
+					p.Put(leases[i])
+					leases = append(leases[:i], leases[i+1:]...)
+				}
+			case 2:
+				p.clean()
+			}
+
+			if err := checkInvariants(p); err != nil {
+				t.Log(err)
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(f, &quick.Config{MaxCount: 50}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestPoolInvariantsConcurrent 并发 Get/Put/clean/Close 下不变式始终成立且不会 panic
+func TestPoolInvariantsConcurrent(t *testing.T) {
+	p := newTestPool(t,
+		WithMaxStreamsClient(4),
+		WithGrpcPoolSize(6),
+		WithMaxIdle(2),
+		WithCleanIntervalTime(time.Millisecond),
+	)
+
+	var (
+		wg   sync.WaitGroup
+		stop = make(chan struct{})
+	)
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+				lc, err := p.GetContext(ctx)
+				cancel()
+				switch err {
+				case nil:
+					if r.Intn(2) == 0 {
+						runtime.Gosched()
+					}
+					p.Put(lc)
+				case ErrPoolClosed:
+					return
+				case context.DeadlineExceeded:
+				default:
+					t.Error(err)
+					return
+				}
+			}
+		}(int64(g))
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			p.clean()
+			if err := checkInvariants(p); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+	close(stop)
+	p.Close()
+	wg.Wait()
+
+	if _, err := p.Get(); err != ErrPoolClosed {
+		t.Fatalf("Get after Close: %v, want %v", err, ErrPoolClosed)
+	}
+	if n := len(p.Stats().Conns); n != 0 {
+		t.Fatalf("%d conns left after Close", n)
+	}
+}
+
+func TestPoolSizeLimit(t *testing.T) {
+	p := newTestPool(t, WithMaxStreamsClient(1), WithGrpcPoolSize(3), WithMaxIdle(1))
+	defer p.Close()
+
+	for i := 0; i < 3; i++ {
+		if _, err := p.Get(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.GetContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("GetContext on exhausted pool: %v, want %v", err, context.DeadlineExceeded)
+	}
+	if n := len(p.Stats().Conns); n != 3 {
+		t.Fatalf("%d conns, want 3", n)
+	}
+}
+
+// fixedClock 固定 Now 以便确定 pick 的起点
+type fixedClock struct {
+	realClock
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
+
+func TestPickWrapsAround(t *testing.T) {
+	// 两个连接时起点为 1，只有回绕才能选到 conns[0]
+	p := newTestPool(t,
+		WithClock(fixedClock{now: time.Unix(0, 1)}),
+		WithMaxStreamsClient(1),
+		WithGrpcPoolSize(2),
+		WithMaxIdle(2),
+		WithNonblocking(),
+	)
+	defer p.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, err := p.Get(); err != nil {
+			t.Fatalf("Get %d: %v", i, err)
+		}
+	}
+	if _, err := p.Get(); err != ErrPoolOverload {
+		t.Fatalf("Get on exhausted nonblocking pool: %v, want %v", err, ErrPoolOverload)
+	}
+}
+
+func TestGetWaitsForPut(t *testing.T) {
+	p := newTestPool(t, WithMaxStreamsClient(1), WithGrpcPoolSize(1), WithMaxIdle(1))
+	defer p.Close()
+
+	lc, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		lc, err := p.Get()
+		if err == nil {
+			p.Put(lc)
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("Get returned %v before Put", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	p.Put(lc)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Get not woken by Put")
+	}
+}
+
+func TestIdleTrim(t *testing.T) {
+	const maxIdle = 2
+	p := newTestPool(t, WithMaxStreamsClient(2), WithMaxIdle(maxIdle), WithCleanIntervalTime(time.Hour))
+	defer p.Close()
+
+	var leases []LogicConn
+	for i := 0; i < 10; i++ {
+		lc, err := p.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		leases = append(leases, lc)
+	}
+	if n := len(p.Stats().Conns); n <= maxIdle {
+		t.Fatalf("%d conns, want more than %d", n, maxIdle)
+	}
+
+	// 有租约的连接不会被清理
+	p.clean()
+	if n := len(p.Stats().Conns); n < 5 {
+		t.Fatalf("%d conns after clean with leases, want at least 5", n)
+	}
+
+	for _, lc := range leases {
+		p.Put(lc)
+	}
+	p.clean()
+	if n, idle := len(p.Stats().Conns), idleConns(p); n != maxIdle || idle != maxIdle {
+		t.Fatalf("%d conns and %d idle after clean, want %d", n, idle, maxIdle)
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	p := newTestPool(t)
+	p.Close()
+	p.Close()
+}
+
+var getPutModes = []struct {
+	name string
+	opts []Option
+}{
+	{"default", nil},
+	{"debug", []Option{WithDebug()}},
+	{"sharded", []Option{WithShards(4)}},
+	{"sharded-debug", []Option{WithShards(4), WithDebug()}},
+}
+
+func TestGetPutAllocs(t *testing.T) {
+	for _, mode := range getPutModes {
+		t.Run(mode.name, func(t *testing.T) {
+			p := newTestPool(t, mode.opts...)
+			defer p.Close()
+
+			allocs := testing.AllocsPerRun(1000, func() {
+				lc, err := p.Get()
+				if err != nil {
+					t.Fatal(err)
+				}
+				p.Put(lc)
+			})
+			if allocs != 0 {
+				t.Fatalf("Get/Put allocates %v times per run", allocs)
+			}
+		})
+	}
+}
+
+func BenchmarkPoolGet(b *testing.B) {
+	for _, mode := range getPutModes {
+		b.Run(mode.name, func(b *testing.B) {
+			p := newTestPool(b, mode.opts...)
+			defer p.Close()
+
+			lcs := make([]LogicConn, 0, defaultMaxIdle*defaultMaxStreamsClient)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				lc, err := p.Get()
+				if err != nil {
+					b.Fatal(err)
+				}
+				lcs = append(lcs, lc)
+				if len(lcs) == cap(lcs) {
+					b.StopTimer()
+					for _, lc := range lcs {
+						p.Put(lc)
+					}
+					lcs = lcs[:0]
+					b.StartTimer()
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPoolGetPut(b *testing.B) {
+	for _, mode := range getPutModes {
+		b.Run(mode.name, func(b *testing.B) {
+			p := newTestPool(b, mode.opts...)
+			defer p.Close()
+
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					lc, err := p.Get()
+					if err != nil {
+						b.Error(err)
+						return
+					}
+					p.Put(lc)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package grpcpool
+
+import (
+	"context"
+	"strconv"
+)
+
+// Priority is the priority class of a lease, see NewPriorityContext.
+type Priority int
+
+const (
+	// PriorityLow is for background traffic such as batch jobs.
+	PriorityLow Priority = iota - 1
+	// PriorityNormal is the priority of a Get without one.
+	PriorityNormal
+	// PriorityHigh is for critical traffic such as health checks and
+	// user-facing reads.
+	PriorityHigh
+)
+
+func (pr Priority) String() string {
+	switch pr {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	}
+	return "priority(" + strconv.Itoa(int(pr)) + ")"
+}
+
+type priorityKey struct{}
+
+// NewPriorityContext returns a copy of ctx carrying the priority pr, which is
+// used by GetContext, Do and Invoke when the pool is saturated.
+func NewPriorityContext(ctx context.Context, pr Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, pr)
+}
+
+// PriorityFromContext returns the priority carried by ctx, PriorityNormal if
+// it carries none.
+func PriorityFromContext(ctx context.Context) Priority {
+	if pr, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return pr
+	}
+	return PriorityNormal
+}
@@ -0,0 +1,118 @@
+package grpcpool_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hunyxv/grpcpool"
+	"github.com/hunyxv/grpcpool/grpcpooltest"
+	"google.golang.org/grpc"
+)
+
+func TestPriorityReserve(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	p := newPool(t, s,
+		grpcpool.WithMaxInFlight(4, grpcpool.ShedPolicy{Mode: grpcpool.ShedReject}),
+		grpcpool.WithPriorityReserve(0.5))
+	defer p.Close()
+
+	high := grpcpool.NewPriorityContext(context.Background(), grpcpool.PriorityHigh)
+	for i := 0; i < 2; i++ {
+		if _, err := p.Get(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	overloadError(t, func() error { _, err := p.Get(); return err }())
+
+	// 保留的名额只给高优先级使用
+	noop := func(grpc.ClientConnInterface) error { return nil }
+	if err := p.Do(context.Background(), noop, grpcpool.AtPriority(grpcpool.PriorityLow)); err == nil {
+		t.Fatal("low priority call admitted into the reserve")
+	}
+	if err := p.Do(context.Background(), noop, grpcpool.AtPriority(grpcpool.PriorityHigh)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := p.GetContext(high); err != nil {
+			t.Fatal(err)
+		}
+	}
+	overloadError(t, func() error { _, err := p.GetContext(high); return err }())
+}
+
+func TestPriorityQueue(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	// 未设置 MaxInFlight 时按总容量 GrpcPoolSize*MaxStreamsClient 排队
+	p := newPool(t, s,
+		grpcpool.WithGrpcPoolSize(1),
+		grpcpool.WithMaxIdle(1),
+		grpcpool.WithMaxStreamsClient(1),
+		grpcpool.WithPriorityReserve(0.5))
+	defer p.Close()
+	if max := p.Stats().MaxInFlight; max != 1 {
+		t.Fatalf("MaxInFlight = %d, want 1", max)
+	}
+
+	lc, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := make(chan grpcpool.Priority, 3)
+	for i, pr := range []grpcpool.Priority{grpcpool.PriorityLow, grpcpool.PriorityNormal, grpcpool.PriorityHigh} {
+		go func(pr grpcpool.Priority) {
+			lc, err := p.GetContext(grpcpool.NewPriorityContext(context.Background(), pr))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			order <- pr
+			p.Put(lc)
+		}(pr)
+		n := i + 1
+		waitFor(t, func() bool { return p.Stats().Queued == n })
+	}
+
+	p.Put(lc)
+	for _, want := range []grpcpool.Priority{grpcpool.PriorityHigh, grpcpool.PriorityNormal, grpcpool.PriorityLow} {
+		if pr := <-order; pr != want {
+			t.Fatalf("%s admitted, want %s", pr, want)
+		}
+	}
+}
+
+func TestPriorityReserveCapacity(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+
+	// 总容量不固定时必须设置 MaxInFlight
+	for _, opts := range [][]grpcpool.Option{
+		{grpcpool.WithPriorityReserve(0.5)},
+		{grpcpool.WithPriorityReserve(0.5), grpcpool.WithGrpcPoolSize(2),
+			grpcpool.WithAdaptiveLimit(grpcpool.AIMD(grpcpool.AIMDConfig{}))},
+		{grpcpool.WithPriorityReserve(0.5), grpcpool.WithGrpcPoolSize(2),
+			grpcpool.WithStreamLimitDiscovery(grpcpool.NewStreamLimitDetector(), 0)},
+	} {
+		if _, err := grpcpool.NewPool(s.Builder(), opts...); err != grpcpool.ErrUnboundedCapacity {
+			t.Fatalf("err = %v, want %v", err, grpcpool.ErrUnboundedCapacity)
+		}
+	}
+
+	// 由 GrpcPoolSize 换算的容量随 Resize 调整
+	p := newPool(t, s,
+		grpcpool.WithGrpcPoolSize(1),
+		grpcpool.WithMaxIdle(1),
+		grpcpool.WithMaxStreamsClient(2),
+		grpcpool.WithPriorityReserve(0.5))
+	defer p.Close()
+	if max := p.Stats().MaxInFlight; max != 2 {
+		t.Fatalf("MaxInFlight = %d, want 2", max)
+	}
+	if err := p.Resize(1, 3); err != nil {
+		t.Fatal(err)
+	}
+	if max := p.Stats().MaxInFlight; max != 6 {
+		t.Fatalf("MaxInFlight = %d after Resize, want 6", max)
+	}
+}
@@ -0,0 +1,261 @@
+package grpcpool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+// RateMode is what a call does when it exceeds a RateLimit.
+type RateMode int
+
+const (
+	// RateWait delays the call until a token is available, it fails with
+	// ErrRateLimited if the token would come after the deadline of the call.
+	RateWait RateMode = iota
+	// RateReject fails the call with ErrRateLimited at once.
+	RateReject
+)
+
+func (m RateMode) String() string {
+	if m == RateReject {
+		return "reject"
+	}
+	return "wait"
+}
+
+// RateLimit configures a token bucket limiting the calls made through
+// Pool.Do and Pool.Invoke, see WithRateLimit and WithMethodRateLimit.
+type RateLimit struct {
+	// Rate is the number of calls per second, 0 or less removes the limit.
+	Rate float64
+	// Burst is the capacity of the bucket, at least 1.
+	Burst int
+	Mode  RateMode
+}
+
+func (l RateLimit) String() string {
+	return fmt.Sprintf("%g/s burst %d %s", l.Rate, l.Burst, l.Mode)
+}
+
+// poolRateKey 连接池级别限流在指标中的 method 标签
+const poolRateKey = "*"
+
+// 限流结果，用作 rateCounter 的 result 标签
+const (
+	rateAllowed = iota
+	rateDelayed
+	rateRejected
+)
+
+var rateResults = [...]string{"allowed", "delayed", "rejected"}
+
+// rateLimiter 单个令牌桶及其预先解析的指标
+type rateLimiter struct {
+	lim      *rate.Limiter
+	mode     RateMode
+	counters [len(rateResults)]prometheus.Counter
+}
+
+// rateLimits 连接池级别以及按方法的限流，见 WithRateLimit
+type rateLimits struct {
+	enabled int32 // 配置过限流后置 1，未配置时调用不加锁
+	mux     sync.RWMutex
+	pool    *rateLimiter
+	methods map[string]*rateLimiter
+}
+
+// SetRateLimit replaces the rate limit of every call made through the pool
+// at runtime, the tokens left in the bucket are kept.
+func (p *Pool) SetRateLimit(limit RateLimit) {
+	p.setRateLimit("", limit)
+}
+
+// SetMethodRateLimit replaces the rate limit of the calls to the full method
+// name at runtime, e.g. "/package.Service/Method".
+func (p *Pool) SetMethodRateLimit(method string, limit RateLimit) {
+	p.setRateLimit(method, limit)
+}
+
+// setRateLimit 更新 method 的令牌桶，method 为空时为连接池级别
+func (p *Pool) setRateLimit(method string, limit RateLimit) {
+	registerMetrics(&rateMetrics, rateCounter)
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+
+	p.mux.Lock()
+	switch {
+	case method == "" && limit.Rate > 0:
+		p.opt.RateLimit = &limit
+	case method == "":
+		p.opt.RateLimit = nil
+	case limit.Rate > 0:
+		if p.opt.MethodRateLimits == nil {
+			p.opt.MethodRateLimits = make(map[string]RateLimit)
+		}
+		p.opt.MethodRateLimits[method] = limit
+	default:
+		delete(p.opt.MethodRateLimits, method)
+	}
+	p.mux.Unlock()
+
+	rl := &p.rates
+	rl.mux.Lock()
+	defer rl.mux.Unlock()
+
+	old := rl.pool
+	if method != "" {
+		old = rl.methods[method]
+	}
+	var next *rateLimiter
+	if limit.Rate > 0 {
+		now := p.opt.Clock.Now()
+		lim := rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst)
+		if old != nil {
+			// 保留桶中剩余的令牌
+			lim = old.lim
+			lim.SetLimitAt(now, rate.Limit(limit.Rate))
+			lim.SetBurstAt(now, limit.Burst)
+		}
+		next = p.newRateLimiter(method, lim, limit.Mode)
+	}
+
+	if method == "" {
+		rl.pool = next
+	} else {
+		if rl.methods == nil {
+			rl.methods = make(map[string]*rateLimiter)
+		}
+		if next == nil {
+			delete(rl.methods, method)
+		} else {
+			rl.methods[method] = next
+		}
+	}
+	if old != nil && next == nil {
+		// 移除的限流不再上报
+		p.deleteRateCounters(method)
+	}
+	atomic.StoreInt32(&rl.enabled, 1)
+}
+
+func (p *Pool) newRateLimiter(method string, lim *rate.Limiter, mode RateMode) *rateLimiter {
+	if method == "" {
+		method = poolRateKey
+	}
+	l := &rateLimiter{lim: lim, mode: mode}
+	for i, result := range rateResults {
+		l.counters[i] = rateCounter.WithLabelValues(p.name, method, result)
+	}
+	return l
+}
+
+// rateWait 按连接池以及 method 的限流获取令牌，method 为空时只检查连接池级别
+func (p *Pool) rateWait(ctx context.Context, method string) error {
+	rl := &p.rates
+	if atomic.LoadInt32(&rl.enabled) == 0 {
+		return nil
+	}
+
+	rl.mux.RLock()
+	limiters := [2]*rateLimiter{rl.pool}
+	if method != "" {
+		limiters[1] = rl.methods[method]
+	}
+	rl.mux.RUnlock()
+
+	var (
+		now      = p.opt.Clock.Now()
+		reserved [2]*rate.Reservation
+		delay    time.Duration
+		reject   bool
+	)
+	for i, l := range limiters {
+		if l == nil {
+			continue
+		}
+		r := l.lim.ReserveN(now, 1)
+		if !r.OK() {
+			reject = true
+			continue
+		}
+		reserved[i] = r
+		d := r.DelayFrom(now)
+		if d > 0 && l.mode == RateReject {
+			reject = true
+		}
+		if d > delay {
+			delay = d
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok && delay > deadline.Sub(now) {
+		reject = true
+	}
+
+	// cancel 归还已预留的令牌
+	cancel := func() {
+		now := p.opt.Clock.Now()
+		for _, r := range reserved {
+			if r != nil {
+				r.CancelAt(now)
+			}
+		}
+	}
+	count := func(result int) {
+		for _, l := range limiters {
+			if l != nil {
+				l.counters[result].Inc()
+			}
+		}
+	}
+
+	switch {
+	case reject:
+		cancel()
+		count(rateRejected)
+		return ErrRateLimited
+	case delay > 0:
+		if err := p.sleep(ctx, delay); err != nil {
+			cancel()
+			return err
+		}
+		count(rateDelayed)
+	default:
+		count(rateAllowed)
+	}
+	return nil
+}
+
+// deleteRateMetrics 连接池关闭后删除限流指标
+func (p *Pool) deleteRateMetrics() {
+	rl := &p.rates
+	if atomic.LoadInt32(&rl.enabled) == 0 {
+		return
+	}
+
+	rl.mux.RLock()
+	defer rl.mux.RUnlock()
+	methods := []string{poolRateKey}
+	for method := range rl.methods {
+		methods = append(methods, method)
+	}
+	for _, method := range methods {
+		p.deleteRateCounters(method)
+	}
+}
+
+// deleteRateCounters 删除 method 的限流指标，method 为空时为连接池级别
+func (p *Pool) deleteRateCounters(method string) {
+	if method == "" {
+		method = poolRateKey
+	}
+	for _, result := range rateResults {
+		rateCounter.DeleteLabelValues(p.name, method, result)
+	}
+}
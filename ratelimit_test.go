@@ -0,0 +1,161 @@
+package grpcpool_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hunyxv/grpcpool"
+	"github.com/hunyxv/grpcpool/grpcpooltest"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestRateLimitReject(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	clock := grpcpooltest.NewFakeClock(time.Now())
+	p := newPool(t, s,
+		grpcpool.WithClock(clock),
+		grpcpool.WithRateLimit(grpcpool.RateLimit{Rate: 10, Burst: 2, Mode: grpcpool.RateReject}))
+	defer p.Close()
+
+	noop := func(grpc.ClientConnInterface) error { return nil }
+	for i := 0; i < 2; i++ {
+		if err := p.Do(context.Background(), noop); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Do(context.Background(), noop); err != grpcpool.ErrRateLimited {
+		t.Fatalf("err = %v, want ErrRateLimited", err)
+	}
+	clock.Advance(100 * time.Millisecond)
+	if err := p.Do(context.Background(), noop); err != nil {
+		t.Fatal(err)
+	}
+
+	// 运行时移除限流
+	p.SetRateLimit(grpcpool.RateLimit{})
+	for i := 0; i < 10; i++ {
+		if err := p.Do(context.Background(), noop); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMethodRateLimitWait(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	clock := grpcpooltest.NewFakeClock(time.Now())
+	p := newPool(t, s,
+		grpcpool.WithClock(clock),
+		grpcpool.WithCleanIntervalTime(time.Hour),
+		grpcpool.WithMethodRateLimit(grpcpooltest.EchoMethod, grpcpool.RateLimit{Rate: 1, Burst: 1}))
+	defer p.Close()
+
+	echo := func(ctx context.Context, method string) error {
+		return p.Invoke(ctx, method, wrapperspb.Bytes(nil), new(wrapperspb.BytesValue))
+	}
+	if err := echo(context.Background(), grpcpooltest.EchoMethod); err != nil {
+		t.Fatal(err)
+	}
+
+	// 其他方法不受限流
+	if err := echo(context.Background(), "/grpcpooltest.Echo/Other"); err == grpcpool.ErrRateLimited {
+		t.Fatal("unlimited method was rate limited")
+	}
+
+	// 截止时间之前拿不到令牌时直接拒绝
+	ctx, cancel := context.WithDeadline(context.Background(), clock.Now().Add(100*time.Millisecond))
+	defer cancel()
+	if err := echo(ctx, grpcpooltest.EchoMethod); err != grpcpool.ErrRateLimited {
+		t.Fatalf("err = %v, want ErrRateLimited", err)
+	}
+
+	waiters := clock.Waiters()
+	done := make(chan error, 1)
+	go func() { done <- echo(context.Background(), grpcpooltest.EchoMethod) }()
+	waitFor(t, func() bool { return clock.Waiters() > waiters })
+	select {
+	case err := <-done:
+		t.Fatalf("call returned before a token was available: %v", err)
+	default:
+	}
+	clock.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// 运行时放宽限流
+	p.SetMethodRateLimit(grpcpooltest.EchoMethod, grpcpool.RateLimit{Rate: 1, Burst: 3, Mode: grpcpool.RateReject})
+	clock.Advance(3 * time.Second)
+	for i := 0; i < 3; i++ {
+		if err := echo(context.Background(), grpcpooltest.EchoMethod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := echo(context.Background(), grpcpooltest.EchoMethod); err != grpcpool.ErrRateLimited {
+		t.Fatalf("err = %v, want ErrRateLimited", err)
+	}
+}
+
+func TestRateLimitRemove(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	p := newPool(t, s,
+		grpcpool.WithName("rate-limit-remove"),
+		grpcpool.WithRateLimit(grpcpool.RateLimit{Rate: 100}),
+		grpcpool.WithMethodRateLimit(grpcpooltest.EchoMethod, grpcpool.RateLimit{Rate: 100}))
+	defer p.Close()
+
+	if err := p.Invoke(context.Background(), grpcpooltest.EchoMethod, wrapperspb.Bytes(nil), new(wrapperspb.BytesValue)); err != nil {
+		t.Fatal(err)
+	}
+	if n := metricSeries(t, "grpcpool_rate_limited_calls", "rate-limit-remove"); n != 6 {
+		t.Fatalf("%d rate limit series, want 6", n)
+	}
+
+	// 移除后不再出现在配置以及指标中
+	p.SetRateLimit(grpcpool.RateLimit{})
+	p.SetMethodRateLimit(grpcpooltest.EchoMethod, grpcpool.RateLimit{})
+	if n := metricSeries(t, "grpcpool_rate_limited_calls", "rate-limit-remove"); n != 0 {
+		t.Fatalf("%d rate limit series after removal, want 0", n)
+	}
+	rec := httptest.NewRecorder()
+	grpcpool.DebugHandler(p).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/grpcpool?format=json", nil))
+	var pools []struct {
+		Options struct{ RateLimit map[string]string }
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &pools); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body)
+	}
+	if limits := pools[0].Options.RateLimit; len(limits) != 0 {
+		t.Fatalf("rate limits %v after removal", limits)
+	}
+}
+
+// metricSeries 返回指标 name 中连接池 pool 的序列数
+func metricSeries(t *testing.T, name, pool string) int {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "pool" && l.GetValue() == pool {
+					n++
+				}
+			}
+		}
+	}
+	return n
+}
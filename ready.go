@@ -0,0 +1,117 @@
+package grpcpool
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// connectMethod 用于唤醒 IDLE 连接的调用，不会真正发送到服务端
+const connectMethod = "/grpcpool.Pool/Connect"
+
+// ensureReady 唤醒 IDLE 的连接，并新建连接直到 READY 或正在建立的连接数
+// 达到 MinReady，调用方需持有 p.mux 写锁
+func (p *Pool) ensureReady() {
+	var ready, pending int
+	for _, gc := range p.conns {
+		if atomic.LoadInt32(&gc.draining) == 1 {
+			continue
+		}
+		switch gc.conn.GetState() {
+		case connectivity.Ready:
+			ready++
+		case connectivity.Idle:
+			connect(gc.conn)
+			pending++
+		case connectivity.Connecting:
+			pending++
+		}
+	}
+
+	for ready+pending < p.opt.MinReady && len(p.conns) < p.opt.GrpcPoolSize {
+		gconn, err := p.build()
+		if err != nil {
+			p.opt.Logger.Printf("warning: %s\n", err.Error())
+			break
+		}
+		p.conns = append(p.conns, gconn)
+		if gconn.isReady() {
+			ready++
+		} else {
+			pending++
+		}
+	}
+
+	readyGauge.WithLabelValues(p.name).Set(float64(ready))
+}
+
+// connect 让 IDLE 的连接开始建立连接。grpc-go 没有导出 ClientConn.Connect，
+// 选取 IDLE 的子连接时会触发重连，这里发起一个已取消的调用
+func connect(conn *grpc.ClientConn) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	conn.Invoke(ctx, connectMethod, new(emptypb.Empty), new(emptypb.Empty))
+}
+
+// ReadyConns returns the number of READY grpcConns which are not draining.
+func (p *Pool) ReadyConns() int {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+	return p.readyConns()
+}
+
+// readyConns 统计 READY 且未排空的连接数，调用方需持有 p.mux
+func (p *Pool) readyConns() int {
+	var ready int
+	for _, gc := range p.conns {
+		if gc.countsReady() {
+			ready++
+		}
+	}
+	return ready
+}
+
+// countsReady 连接是否计入 MinReady 的 READY 连接
+func (gc *grpcConn) countsReady() bool {
+	return atomic.LoadInt32(&gc.draining) == 0 && gc.isReady()
+}
+
+// Ready reports whether the pool has at least MinReady READY grpcConns,
+// at least one if WithMinReady is not set.
+func (p *Pool) Ready() bool {
+	return p.isReady(p.ReadyConns())
+}
+
+func (p *Pool) isReady(ready int) bool {
+	if atomic.LoadInt32(&p.state) == CLOSED {
+		return false
+	}
+	return ready >= p.opt.MinReady && ready > 0
+}
+
+// ReadinessHandler returns a http.Handler for readiness probes, it responds
+// 200 if every pool is Ready and 503 otherwise.
+func ReadinessHandler(pools ...*Pool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := http.StatusOK
+		var body string
+		for _, p := range pools {
+			ready := p.ReadyConns()
+			state := "ready"
+			if !p.isReady(ready) {
+				status = http.StatusServiceUnavailable
+				state = "not ready"
+			}
+			body += fmt.Sprintf("%s: %s, %d ready connections, min %d\n", p.name, state, ready, p.opt.MinReady)
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	})
+}
@@ -0,0 +1,140 @@
+package grpcpool_test
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hunyxv/grpcpool"
+	"github.com/hunyxv/grpcpool/grpcpooltest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+func states(p *grpcpool.Pool) map[connectivity.State]int {
+	m := make(map[connectivity.State]int)
+	for _, cs := range p.Stats().Conns {
+		m[cs.State]++
+	}
+	return m
+}
+
+func TestMinReadyReplacesBrokenConns(t *testing.T) {
+	dead := grpcpooltest.NewServer()
+	dead.Close()
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+
+	// 前两个连接拨向已关闭的服务端，无法进入 READY
+	var dials int
+	p, err := grpcpool.NewPool(func() (*grpc.ClientConn, error) {
+		dials++
+		dialer := s.DialContext
+		if dials <= 2 {
+			dialer = dead.DialContext
+		}
+		return grpc.Dial("passthrough:///bufconn", grpc.WithInsecure(), grpc.WithContextDialer(dialer))
+	},
+		grpcpool.WithMaxIdle(2),
+		grpcpool.WithGrpcPoolSize(4),
+		grpcpool.WithMinReady(2),
+		grpcpool.WithCleanIntervalTime(10*time.Millisecond),
+		grpcpool.WithLogger(log.New(ioutil.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	waitFor(t, p.Ready)
+	if n := states(p)[connectivity.Ready]; n != 2 {
+		t.Fatalf("%d READY conns, want 2", n)
+	}
+	// 多余的空闲连接中优先清理失败的连接
+	waitFor(t, func() bool { return len(p.Stats().Conns) == 2 })
+	if n := states(p)[connectivity.Ready]; n != 2 {
+		t.Fatalf("%d READY conns after trim, want 2", n)
+	}
+}
+
+func TestMinReadyDialsReplacements(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	p := newPool(t, s,
+		grpcpool.WithMaxIdle(1),
+		grpcpool.WithMinReady(2),
+		grpcpool.WithCleanIntervalTime(10*time.Millisecond))
+	defer p.Close()
+
+	if n := len(p.Stats().Conns); n != 2 {
+		t.Fatalf("%d conns, want MaxIdle raised to 2", n)
+	}
+	if !p.Ready() {
+		t.Fatal("pool is not ready")
+	}
+
+	// 驱逐一个连接后补充到 MinReady
+	if err := p.Evict(p.Stats().Conns[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if p.Ready() {
+		t.Fatal("pool is ready with 1 conn")
+	}
+	waitFor(t, p.Ready)
+}
+
+func TestMinReadyKeepsIdleConns(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	clock := grpcpooltest.NewFakeClock(time.Now())
+	p := newPool(t, s,
+		grpcpool.WithClock(clock),
+		grpcpool.WithMaxIdle(2),
+		grpcpool.WithGrpcPoolSize(4),
+		grpcpool.WithMinReady(2),
+		grpcpool.WithClientIdleTimeout(time.Minute),
+		grpcpool.WithCleanIntervalTime(time.Hour))
+	defer p.Close()
+
+	// 空闲超时不会让 READY 的连接少于 MinReady，Resize 立即清理一次
+	before := connIDs(p)
+	clock.Advance(61 * time.Second)
+	if err := p.Resize(2, 4); err != nil {
+		t.Fatal(err)
+	}
+	ids := connIDs(p)
+	if len(ids) != 2 {
+		t.Fatalf("%d conns, want 2", len(ids))
+	}
+	for id := range ids {
+		if !before[id] {
+			t.Fatalf("idle conns replaced: %v -> %v", before, ids)
+		}
+	}
+	if !p.Ready() {
+		t.Fatal("pool is not ready")
+	}
+}
+
+func TestReadinessHandler(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	p := newPool(t, s, grpcpool.WithName("backend"), grpcpool.WithMinReady(1))
+	defer p.Close()
+	h := grpcpool.ReadinessHandler(p)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+
+	s.Close()
+	waitFor(t, func() bool { return !p.Ready() })
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503: %s", rec.Code, rec.Body)
+	}
+}
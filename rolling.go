@@ -0,0 +1,231 @@
+package grpcpool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// RollingOpts configures Pool.RollingRestart.
+type RollingOpts struct {
+	// MaxUnavailable is the number of grpcConns replaced at once, 1 by default.
+	// The replacements are READY before the old grpcConns are drained, so the
+	// pool may exceed GrpcPoolSize by as many meanwhile.
+	MaxUnavailable int
+
+	// Pause is the time waited between two batches.
+	Pause time.Duration
+
+	// ReadyTimeout bounds the wait for a replacement to become READY,
+	// 0 means until ctx is done.
+	ReadyTimeout time.Duration
+
+	// Progress is called after each grpcConn is replaced, skipped or failed
+	// to be.
+	Progress func(RollingProgress)
+}
+
+// RollingProgress reports the replacement of one grpcConn.
+type RollingProgress struct {
+	// Total is the number of grpcConns to replace
+	Total int
+	// Replaced, Skipped and Failed count the grpcConns handled so far.
+	// A grpcConn already closed when its turn comes is skipped, the cleaner
+	// removes it.
+	Replaced int
+	Skipped  int
+	Failed   int
+
+	OldID int32
+	// NewID is 0 if the grpcConn was skipped or its replacement failed
+	NewID int32
+	Err   error
+}
+
+// RollingRestart replaces every grpcConn of the pool through the Builder
+// batch by batch, e.g. after a backend rollout or a DNS change. For each
+// grpcConn a new one is dialed and waited until READY, then the old one is
+// drained and closed once its leases are returned. A grpcConn whose
+// replacement fails is kept, one already closed is skipped. If ctx is done
+// RollingRestart stops after closing the replacements not added yet, the old
+// grpcConns already draining are closed by the cleaner.
+func (p *Pool) RollingRestart(ctx context.Context, opts RollingOpts) error {
+	return p.rolling(ctx, opts, "restarted")
+}
+
+func (p *Pool) rolling(ctx context.Context, opts RollingOpts, reason string) error {
+	if opts.MaxUnavailable < 1 {
+		opts.MaxUnavailable = 1
+	}
+
+	p.mux.RLock()
+	old := append([]*grpcConn(nil), p.conns...)
+	p.mux.RUnlock()
+
+	var (
+		mux      sync.Mutex
+		progress = RollingProgress{Total: len(old)}
+		firstErr error
+	)
+	report := func(gc *grpcConn, newID int32, err error) {
+		mux.Lock()
+		defer mux.Unlock()
+
+		progress.OldID, progress.NewID, progress.Err = gc.id, newID, err
+		switch {
+		case err != nil:
+			progress.Failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+		case newID == 0:
+			progress.Skipped++
+		default:
+			progress.Replaced++
+		}
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+	}
+
+	for start := 0; start < len(old); start += opts.MaxUnavailable {
+		if start > 0 && opts.Pause > 0 {
+			if err := p.sleep(ctx, opts.Pause); err != nil {
+				return err
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		end := start + opts.MaxUnavailable
+		if end > len(old) {
+			end = len(old)
+		}
+		var wg sync.WaitGroup
+		for _, gc := range old[start:end] {
+			wg.Add(1)
+			go func(gc *grpcConn) {
+				defer wg.Done()
+				newID, err := p.replace(ctx, gc, opts.ReadyTimeout, reason)
+				if err == nil || ctx.Err() == nil {
+					report(gc, newID, err)
+				}
+			}(gc)
+		}
+		wg.Wait()
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if firstErr != nil {
+		return fmt.Errorf("grpc pool: %d of %d connections not replaced: %w", progress.Failed, progress.Total, firstErr)
+	}
+	return nil
+}
+
+// replace 创建新连接，READY 后替换 gc，排空 gc 的租约后关闭 gc，返回新连接的 id。
+// gc 已经关闭时不替换，返回 0
+func (p *Pool) replace(ctx context.Context, gc *grpcConn, readyTimeout time.Duration, reason string) (int32, error) {
+	if gc.isClosed() {
+		return 0, nil
+	}
+
+	// 在锁外拨号，Builder 可能阻塞
+	gconn, err := p.build()
+	if err != nil {
+		return 0, err
+	}
+	if err := p.waitReady(ctx, gconn.conn, readyTimeout); err != nil {
+		p.discard(gconn)
+		return 0, err
+	}
+
+	p.mux.Lock()
+	if atomic.LoadInt32(&p.state) == CLOSED {
+		p.mux.Unlock()
+		p.discard(gconn)
+		return 0, ErrPoolClosed
+	}
+	p.conns = append(p.conns, gconn)
+	if gc.drain() {
+		p.event(EventConnDraining, gc.id, reason)
+	}
+	p.reshard()
+	p.mux.Unlock()
+
+	select {
+	case <-gc.drained:
+	case <-ctx.Done():
+		return gconn.id, ctx.Err()
+	}
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if i := p.indexOf(gc.id); i >= 0 {
+		p.removeConn(i, reason)
+		p.reshard()
+	}
+	return gconn.id, nil
+}
+
+// discard 关闭未加入连接池的 grpcConn
+func (p *Pool) discard(gc *grpcConn) {
+	p.event(EventConnClosed, gc.id, "discarded")
+	if err := gc.close(); err != nil {
+		p.opt.Logger.Printf("warning: %s\n", err.Error())
+	}
+	if p.opt.Debug {
+		connGauge.Dec()
+	}
+}
+
+// sleep 按连接池的时钟等待 d
+func (p *Pool) sleep(ctx context.Context, d time.Duration) error {
+	ch := make(chan struct{})
+	timer := p.opt.Clock.AfterFunc(d, func() { close(ch) })
+	defer timer.Stop()
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// waitReady 等待连接进入 READY 状态，timeout 为 0 时不限时
+func (p *Pool) waitReady(ctx context.Context, conn *grpc.ClientConn, timeout time.Duration) (err error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		parent := ctx
+		ctx, cancel = context.WithCancel(ctx)
+		timer := p.opt.Clock.AfterFunc(timeout, cancel)
+		defer func() {
+			timer.Stop()
+			cancel()
+			if err == context.Canceled && parent.Err() == nil {
+				err = ErrNotReady
+			}
+		}()
+	}
+
+	for {
+		state := conn.GetState()
+		switch state {
+		case connectivity.Ready:
+			return nil
+		case connectivity.Shutdown:
+			return ErrConnClosed
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return ctx.Err()
+		}
+	}
+}
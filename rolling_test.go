@@ -0,0 +1,144 @@
+package grpcpool_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/hunyxv/grpcpool"
+	"github.com/hunyxv/grpcpool/grpcpooltest"
+	"google.golang.org/grpc"
+)
+
+func TestRollingRestart(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	p := newPool(t, s, grpcpool.WithMaxIdle(4), grpcpool.WithCleanIntervalTime(time.Hour))
+	defer p.Close()
+
+	old := connIDs(p)
+	var progress []grpcpool.RollingProgress
+	err := p.RollingRestart(context.Background(), grpcpool.RollingOpts{
+		MaxUnavailable: 2,
+		Pause:          time.Millisecond,
+		Progress:       func(rp grpcpool.RollingProgress) { progress = append(progress, rp) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(progress) != 4 {
+		t.Fatalf("%d progress reports, want 4", len(progress))
+	}
+	if last := progress[3]; last.Total != 4 || last.Replaced != 4 || last.Failed != 0 {
+		t.Fatalf("unexpected progress: %+v", last)
+	}
+	ids := connIDs(p)
+	if len(ids) != 4 {
+		t.Fatalf("%d conns after restart, want 4", len(ids))
+	}
+	for _, rp := range progress {
+		if !old[rp.OldID] || !ids[rp.NewID] {
+			t.Fatalf("unexpected replacement: %+v", rp)
+		}
+	}
+}
+
+// TestRollingRestartSkipsClosed 已关闭的连接不替换，也不计入 Replaced
+func TestRollingRestartSkipsClosed(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	fi := grpcpool.NewFaultInjector(grpcpool.FaultRule{Rate: 1, KillConn: true})
+	p := newPool(t, s,
+		grpcpool.WithFaultInjector(fi),
+		grpcpool.WithMaxIdle(2),
+		grpcpool.WithCleanIntervalTime(time.Hour))
+	defer p.Close()
+
+	// 注入的连接中断关闭一个连接，由 cleaner 移除
+	if err := invokeEcho(p); err == nil {
+		t.Fatal("killed call succeeded")
+	}
+	var progress []grpcpool.RollingProgress
+	err := p.RollingRestart(context.Background(), grpcpool.RollingOpts{
+		Progress: func(rp grpcpool.RollingProgress) { progress = append(progress, rp) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(progress) != 2 {
+		t.Fatalf("%d progress reports, want 2", len(progress))
+	}
+	if last := progress[1]; last.Replaced != 1 || last.Skipped != 1 || last.Failed != 0 {
+		t.Fatalf("unexpected progress: %+v", last)
+	}
+	for _, rp := range progress {
+		if rp.Err != nil {
+			t.Fatalf("unexpected error: %+v", rp)
+		}
+	}
+}
+
+func TestRollingRestartNotReady(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	// 不阻塞地拨号，由 RollingRestart 等待 READY
+	p, err := grpcpool.NewPool(func() (*grpc.ClientConn, error) {
+		return grpc.Dial("passthrough:///bufconn", grpc.WithInsecure(), grpc.WithContextDialer(s.DialContext))
+	}, grpcpool.WithMaxIdle(2), grpcpool.WithCleanIntervalTime(time.Hour), grpcpool.WithLogger(log.New(ioutil.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	// 服务端关闭后新连接无法进入 READY
+	s.Close()
+	old := connIDs(p)
+	var failed int
+	err = p.RollingRestart(context.Background(), grpcpool.RollingOpts{
+		MaxUnavailable: 2,
+		ReadyTimeout:   50 * time.Millisecond,
+		Progress:       func(rp grpcpool.RollingProgress) { failed = rp.Failed },
+	})
+	if !errors.Is(err, grpcpool.ErrNotReady) {
+		t.Fatalf("err = %v, want ErrNotReady", err)
+	}
+	if failed != 2 {
+		t.Fatalf("%d failed, want 2", failed)
+	}
+	ids := connIDs(p)
+	if len(ids) != 2 || !old[p.Stats().Conns[0].ID] || !old[p.Stats().Conns[1].ID] {
+		t.Fatalf("old conns not kept: %v", ids)
+	}
+}
+
+func TestRollingRestartCancel(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	clock := grpcpooltest.NewFakeClock(time.Now())
+	p := newPool(t, s, grpcpool.WithMaxIdle(3), grpcpool.WithClock(clock), grpcpool.WithCleanIntervalTime(time.Hour))
+	defer p.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var replaced int
+	err := p.RollingRestart(ctx, grpcpool.RollingOpts{
+		Pause: time.Minute,
+		Progress: func(rp grpcpool.RollingProgress) {
+			replaced = rp.Replaced
+			// 第一批完成后在暂停期间取消
+			cancel()
+		},
+	})
+	if err != context.Canceled {
+		t.Fatalf("err = %v, want Canceled", err)
+	}
+	if replaced != 1 {
+		t.Fatalf("%d replaced, want 1", replaced)
+	}
+	if n := len(p.Stats().Conns); n != 3 {
+		t.Fatalf("%d conns after cancel, want 3", n)
+	}
+}
@@ -0,0 +1,33 @@
+package grpcpool
+
+import "context"
+
+// Rotate replaces every grpcConn of the pool through the Builder, e.g. after
+// the credentials it dials with have changed. Each replacement is READY and
+// added to the pool before the old grpcConn is drained and closed, so the
+// capacity never drops. RotateConcurrency grpcConns are replaced at once,
+// see RollingRestart.
+func (p *Pool) Rotate(ctx context.Context) error {
+	return p.rolling(ctx, RollingOpts{MaxUnavailable: p.opt.RotateConcurrency}, "rotated")
+}
+
+// watchCredentials 凭证变化时轮换所有连接
+func (p *Pool) watchCredentials(changed <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-p.ch
+		cancel()
+	}()
+
+	for {
+		select {
+		case <-changed:
+			if err := p.Rotate(ctx); err != nil && ctx.Err() == nil {
+				p.opt.Logger.Printf("warning: rotate: %s\n", err.Error())
+			}
+		case <-p.ch:
+			return
+		}
+	}
+}
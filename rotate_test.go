@@ -0,0 +1,159 @@
+package grpcpool_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hunyxv/grpcpool"
+	"github.com/hunyxv/grpcpool/grpcpooltest"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestRotate(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	p := newPool(t, s,
+		grpcpool.WithMaxIdle(3),
+		grpcpool.WithRotateConcurrency(3),
+		grpcpool.WithCleanIntervalTime(time.Hour))
+	defer p.Close()
+
+	old := connIDs(p)
+	lc, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- p.Rotate(context.Background()) }()
+
+	// 租约未归还前旧连接一直保留，并且不再发放新的租约
+	waitFor(t, func() bool { return len(p.Stats().Conns) == 4 })
+	select {
+	case err := <-done:
+		t.Fatalf("Rotate returned with an outstanding lease: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	for i := 0; i < 10; i++ {
+		lc, err := p.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Put(lc)
+	}
+	for _, cs := range p.Stats().Conns {
+		if old[cs.ID] && cs.InFlight != 1 {
+			t.Fatalf("lease granted on rotated conn %d", cs.ID)
+		}
+	}
+
+	p.Put(lc)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	ids := connIDs(p)
+	if len(ids) != 3 {
+		t.Fatalf("%d conns after rotation, want 3", len(ids))
+	}
+	for id := range ids {
+		if old[id] {
+			t.Fatalf("conn %d was not rotated", id)
+		}
+	}
+	if dials := s.Dials(); dials != 6 {
+		t.Fatalf("%d dials, want 6", dials)
+	}
+}
+
+func TestRotateConcurrentGet(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	p := newPool(t, s,
+		grpcpool.WithShards(4),
+		grpcpool.WithMaxIdle(4),
+		grpcpool.WithMaxStreamsClient(4),
+		grpcpool.WithRotateConcurrency(4),
+		grpcpool.WithCleanIntervalTime(time.Hour))
+	defer p.Close()
+
+	// 排空开始的同时发放的租约不能落在已经关闭的连接上
+	var (
+		wg   sync.WaitGroup
+		stop int32
+	)
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt32(&stop) == 0 {
+				lc, err := p.Get()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				err = lc.Conn().Invoke(context.Background(), grpcpooltest.EchoMethod, new(emptypb.Empty), new(emptypb.Empty))
+				p.Put(lc)
+				if err != nil {
+					t.Errorf("call on a leased conn: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		if err := p.Rotate(context.Background()); err != nil {
+			t.Error(err)
+			break
+		}
+	}
+	atomic.StoreInt32(&stop, 1)
+	wg.Wait()
+}
+
+func TestRotateCancel(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	p := newPool(t, s, grpcpool.WithMaxIdle(1), grpcpool.WithCleanIntervalTime(10*time.Millisecond))
+	defer p.Close()
+
+	old := connIDs(p)
+	lc, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := p.Rotate(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("err = %v, want DeadlineExceeded", err)
+	}
+
+	// 取消后剩余的旧连接由清理协程在排空后关闭
+	p.Put(lc)
+	waitFor(t, func() bool {
+		conns := p.Stats().Conns
+		return len(conns) == 1 && !old[conns[0].ID]
+	})
+}
+
+func TestCredentialWatcher(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	changed := make(chan struct{}, 1)
+	p := newPool(t, s, grpcpool.WithMaxIdle(2), grpcpool.WithCredentialWatcher(changed))
+	defer p.Close()
+
+	old := connIDs(p)
+	changed <- struct{}{}
+	waitFor(t, func() bool {
+		ids := connIDs(p)
+		for id := range ids {
+			if old[id] {
+				return false
+			}
+		}
+		return len(ids) == 2
+	})
+}
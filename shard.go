@@ -0,0 +1,123 @@
+package grpcpool
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// shard 一组 grpcConn，拥有独立的锁和选取游标，分片模式下 Get 优先从
+// 当前 P 对应的分片获取连接，失败时从其他分片窃取
+type shard struct {
+	mux    sync.RWMutex
+	conns  []*grpcConn
+	cursor uint32
+
+	_ [64]byte // 避免相邻分片伪共享
+}
+
+// pick 从分片中轮询选取一个可用的 grpcConn
+func (s *shard) pick(skip func(*grpcConn) bool) LogicConn {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	l := len(s.conns)
+	if l == 0 {
+		return nil
+	}
+
+	start := int(atomic.AddUint32(&s.cursor, 1) % uint32(l))
+	for i := 0; i < l; i++ {
+		gc := s.conns[(start+i)%l]
+		if skip != nil && skip(gc) {
+			continue
+		}
+		if logicconn, err := gc.take(); err == nil {
+			return logicconn
+		}
+	}
+	return nil
+}
+
+func (s *shard) allBroken() bool {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	for _, gc := range s.conns {
+		if !gc.isBroken() {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *shard) set(conns []*grpcConn) {
+	s.mux.Lock()
+	s.conns = conns
+	s.mux.Unlock()
+}
+
+func (p *Pool) initShards(n int) {
+	p.shards = make([]*shard, n)
+	for i := range p.shards {
+		p.shards[i] = new(shard)
+	}
+
+	// sync.Pool 按 P 缓存对象，同一个 P 上的 goroutine 大概率拿到相同的分片
+	var next uint32
+	p.shardHint.New = func() interface{} {
+		i := int(atomic.AddUint32(&next, 1) % uint32(n))
+		return &i
+	}
+}
+
+// reshard 将 p.conns 重新分配到各个分片，调用方需持有 p.mux 写锁
+func (p *Pool) reshard() {
+	atomic.StoreInt32(&p.size, int32(len(p.conns)))
+	// 连接被替换或熔断冷却后（clean 每次都会调用），等待的 lease 可以重新选取
+	p.notifyRelease()
+	if p.shards == nil {
+		return
+	}
+
+	n := len(p.shards)
+	parts := make([][]*grpcConn, n)
+	for i, gc := range p.conns {
+		parts[i%n] = append(parts[i%n], gc)
+	}
+	for i, s := range p.shards {
+		s.set(parts[i])
+	}
+}
+
+func (p *Pool) localShard() int {
+	hint := p.shardHint.Get().(*int)
+	i := *hint
+	p.shardHint.Put(hint)
+	return i
+}
+
+// pickSharded 分片模式下选取 grpcConn，先尝试本地分片，再从其他分片窃取
+func (p *Pool) pickSharded(skip func(*grpcConn) bool) (LogicConn, int, error) {
+	l := int(atomic.LoadInt32(&p.size))
+	n := len(p.shards)
+	local := p.localShard()
+	for i := 0; i < n; i++ {
+		if logicconn := p.shards[(local+i)%n].pick(skip); logicconn != nil {
+			return logicconn, l, nil
+		}
+	}
+
+	if p.opt.CircuitBreaker != nil && l > 0 {
+		broken := true
+		for _, s := range p.shards {
+			if !s.allBroken() {
+				broken = false
+				break
+			}
+		}
+		if broken {
+			return nil, l, ErrCircuitOpen
+		}
+	}
+	return nil, l, nil
+}
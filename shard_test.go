@@ -0,0 +1,75 @@
+package grpcpool
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestShardedGetPut(t *testing.T) {
+	p := newTestPool(t, WithShards(4), WithMaxIdle(8), WithMaxStreamsClient(4))
+	defer p.Close()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				lc, err := p.Get()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				p.Put(lc)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, s := range p.Stats().Conns {
+		if s.InFlight != 0 {
+			t.Fatalf("conn %d has %d leases in flight", s.ID, s.InFlight)
+		}
+	}
+}
+
+func BenchmarkGetPut(b *testing.B) {
+	for _, shards := range []int{0, 8} {
+		for _, goroutines := range []int{1, 2, 4, 8, 16, 32, 64} {
+			name := fmt.Sprintf("shards-%d/goroutines-%d", shards, goroutines)
+			b.Run(name, func(b *testing.B) {
+				p := newTestPool(b, WithShards(shards), WithMaxIdle(16))
+				defer p.Close()
+				benchmarkGetPut(b, p, goroutines)
+			})
+		}
+	}
+}
+
+func benchmarkGetPut(b *testing.B, p *Pool, goroutines int) {
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		n := b.N / goroutines
+		if g < b.N%goroutines {
+			n++
+		}
+
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				lc, err := p.Get()
+				if err != nil {
+					b.Error(err)
+					return
+				}
+				p.Put(lc)
+			}
+		}(n)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,49 @@
+package grpcpool
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func http2Frame(typ, flags byte, payload []byte) []byte {
+	b := []byte{byte(len(payload) >> 16), byte(len(payload) >> 8), byte(len(payload)), typ, flags, 0, 0, 0, 0}
+	return append(b, payload...)
+}
+
+func settingsFrame(maxStreams uint32) []byte {
+	payload := make([]byte, 12)
+	binary.BigEndian.PutUint16(payload, 0x4) // SETTINGS_INITIAL_WINDOW_SIZE
+	binary.BigEndian.PutUint32(payload[2:], 1<<20)
+	binary.BigEndian.PutUint16(payload[6:], http2SettingMaxConcurrentStrs)
+	binary.BigEndian.PutUint32(payload[8:], maxStreams)
+	return http2Frame(http2FrameSettings, 0, payload)
+}
+
+func TestSniffSettings(t *testing.T) {
+	var stream []byte
+	stream = append(stream, settingsFrame(10)...)
+	stream = append(stream, http2Frame(0x0, 0, make([]byte, 100))...) // DATA
+	stream = append(stream, http2Frame(http2FrameSettings, http2FlagSettingsAck, nil)...)
+	stream = append(stream, settingsFrame(4)...)
+	first := len(settingsFrame(10))
+
+	// 帧可能被拆分到任意多次 Read 中
+	for _, chunk := range []int{1, 7, 64, len(stream)} {
+		d := NewStreamLimitDetector()
+		l := d.newConnStreamLimit()
+		c := &sniffConn{l: l}
+		for i := 0; i < len(stream); i += chunk {
+			end := i + chunk
+			if end > len(stream) {
+				end = len(stream)
+			}
+			c.sniff(stream[i:end])
+			if end >= first && end < len(stream) && l.get() != 10 {
+				t.Fatalf("chunk %d: limit %d after the first SETTINGS, want 10", chunk, l.get())
+			}
+		}
+		if l.get() != 4 || d.Limit() != 4 {
+			t.Fatalf("chunk %d: limit %d, detector %d, want 4", chunk, l.get(), d.Limit())
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package grpcpool
+
+import (
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/connectivity"
+)
+
+// Stats 连接池统计信息
+type Stats struct {
+	Name     string
+	Closed   bool
+	MaxIdle  int
+	PoolSize int
+
+	// StreamLimit 服务端通告的 SETTINGS_MAX_CONCURRENT_STREAMS，未知时为 0
+	StreamLimit int
+
+	// InFlight 与 Queued 连接池级别的在途及排队租约数，
+	// 见 WithMaxInFlight 以及 WithPriorityReserve
+	InFlight    int
+	Queued      int
+	MaxInFlight int
+
+	// Tenants 各租户的使用情况，见 WithTenants
+	Tenants []TenantUsage
+
+	// CrossZoneLeases 发放到其他可用区连接的租约数，见 WithLocalZone
+	CrossZoneLeases uint64
+
+	Conns []ConnStats
+}
+
+// ConnStats 单个 grpcConn 的统计信息
+type ConnStats struct {
+	ID     int32
+	Target string
+	// ChannelzID grpc-go channelz 中 ClientConn 的 channel ID，未知时为 0，见 WithChannelz
+	ChannelzID int64
+	// Locality 由 TargetBuilder 提供
+	Locality Locality
+
+	State    connectivity.State
+	InFlight int
+	// Limit 当前容量，启用自适应限制时动态变化
+	Limit int
+	// Created 连接创建时间
+	Created time.Time
+	// LastUsed 最近一次获取租约的时间
+	LastUsed time.Time
+}
+
+// Stats returns a snapshot of the pool statistics.
+func (p *Pool) Stats() Stats {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+
+	stats := Stats{
+		Name:            p.name,
+		Closed:          atomic.LoadInt32(&p.state) == CLOSED,
+		MaxIdle:         p.opt.MaxIdle,
+		PoolSize:        p.opt.GrpcPoolSize,
+		CrossZoneLeases: atomic.LoadUint64(&p.crossZone),
+		Conns:           make([]ConnStats, 0, len(p.conns)),
+	}
+	if p.opt.StreamLimit != nil {
+		stats.StreamLimit = p.opt.StreamLimit.Limit()
+	}
+	if p.admission != nil {
+		stats.InFlight, stats.Queued, stats.MaxInFlight = p.admission.counts()
+		stats.Tenants = p.admission.usage()
+	}
+	for _, gc := range p.conns {
+		stats.Conns = append(stats.Conns, gc.stats())
+	}
+	return stats
+}
+
+func (gc *grpcConn) stats() ConnStats {
+	return ConnStats{
+		ID:         gc.id,
+		Target:     gc.conn.Target(),
+		ChannelzID: channelzID(gc.channelzToken),
+		Locality:   gc.locality,
+		State:      gc.conn.GetState(),
+		InFlight:   gc.inflight(),
+		Limit:      gc.capacity(),
+		Created:    time.Unix(0, gc.created),
+		LastUsed:   time.Unix(0, atomic.LoadInt64(&gc.ts)),
+	}
+}
@@ -0,0 +1,230 @@
+package grpcpool
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	http2FrameHeaderLen           = 9
+	http2FrameSettings            = 0x4
+	http2FlagSettingsAck          = 0x1
+	http2SettingMaxConcurrentStrs = 0x3
+
+	// SETTINGS 帧超过 HTTP/2 默认的最大帧长度时放弃解析
+	maxSettingsLen = 16 << 10
+)
+
+// StreamLimitDetector learns the server's HTTP/2 SETTINGS_MAX_CONCURRENT_STREAMS
+// from the connections dialed with it, see WithStreamLimitDiscovery.
+//
+// The pool's Builder should call DialOption, ContextDialer or
+// TransportCredentials on every dial, the limit is then tracked per
+// connection, including the connections re-established after a GOAWAY.
+// An option shared by several connections tracks the latest limit advertised
+// on any of them.
+//
+// Use DialOption or ContextDialer for plaintext connections and
+// TransportCredentials for secure ones, never both on the same connection.
+type StreamLimitDetector struct {
+	limit uint32 // 任意连接上最近一次通告的限制
+
+	mux  sync.Mutex
+	last *connStreamLimit // 最近一次创建的 connStreamLimit，见 take
+}
+
+// NewStreamLimitDetector creates a StreamLimitDetector.
+func NewStreamLimitDetector() *StreamLimitDetector {
+	return new(StreamLimitDetector)
+}
+
+// Limit returns the latest SETTINGS_MAX_CONCURRENT_STREAMS advertised by
+// the server on any connection, 0 if it is unknown.
+func (d *StreamLimitDetector) Limit() int {
+	return int(atomic.LoadUint32(&d.limit))
+}
+
+// DialOption returns a grpc.DialOption which dials plaintext tcp connections
+// and records the stream limit from them.
+func (d *StreamLimitDetector) DialOption() grpc.DialOption {
+	return d.ContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	})
+}
+
+// ContextDialer returns a grpc.DialOption which dials plaintext connections
+// with dial and records the stream limit from them.
+func (d *StreamLimitDetector) ContextDialer(dial func(context.Context, string) (net.Conn, error)) grpc.DialOption {
+	l := d.newConnStreamLimit()
+	return grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, addr)
+		if err != nil {
+			return nil, err
+		}
+		return l.wrap(conn), nil
+	})
+}
+
+// TransportCredentials wraps creds so the stream limit is recorded from the
+// connection after the handshake.
+func (d *StreamLimitDetector) TransportCredentials(creds credentials.TransportCredentials) credentials.TransportCredentials {
+	return &detectorCreds{TransportCredentials: creds, l: d.newConnStreamLimit()}
+}
+
+func (d *StreamLimitDetector) newConnStreamLimit() *connStreamLimit {
+	l := &connStreamLimit{d: d}
+	d.mux.Lock()
+	d.last = l
+	d.mux.Unlock()
+	return l
+}
+
+// take 返回上次调用以来最近创建的 connStreamLimit，没有时返回 nil
+func (d *StreamLimitDetector) take() *connStreamLimit {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	l := d.last
+	d.last = nil
+	return l
+}
+
+// connStreamLimit 使用同一个 DialOption 的连接（通常是一个 ClientConn 及其重连）
+// 上服务端通告的流数限制
+type connStreamLimit struct {
+	d     *StreamLimitDetector
+	limit uint32
+
+	mux    sync.Mutex
+	notify func() // 限制变化时调用，见 watch
+}
+
+func (l *connStreamLimit) get() int {
+	return int(atomic.LoadUint32(&l.limit))
+}
+
+func (l *connStreamLimit) set(limit uint32) {
+	atomic.StoreUint32(&l.d.limit, limit)
+	if atomic.SwapUint32(&l.limit, limit) == limit {
+		return
+	}
+
+	l.mux.Lock()
+	notify := l.notify
+	l.mux.Unlock()
+	if notify != nil {
+		notify()
+	}
+}
+
+// watch 设置限制变化时的回调
+func (l *connStreamLimit) watch(f func()) {
+	l.mux.Lock()
+	l.notify = f
+	l.mux.Unlock()
+}
+
+func (l *connStreamLimit) wrap(conn net.Conn) net.Conn {
+	return &sniffConn{Conn: conn, l: l}
+}
+
+type detectorCreds struct {
+	credentials.TransportCredentials
+
+	l *connStreamLimit
+}
+
+func (c *detectorCreds) ClientHandshake(ctx context.Context, authority string, rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	conn, authInfo, err := c.TransportCredentials.ClientHandshake(ctx, authority, rawConn)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.l.wrap(conn), authInfo, nil
+}
+
+func (c *detectorCreds) Clone() credentials.TransportCredentials {
+	return &detectorCreds{TransportCredentials: c.TransportCredentials.Clone(), l: c.l}
+}
+
+// sniffConn 解析服务端发送的 HTTP/2 帧，记录每个 SETTINGS 帧中的流数限制。
+// 只缓存帧头以及 SETTINGS 帧，其他帧的负载直接跳过
+type sniffConn struct {
+	net.Conn
+
+	l    *connStreamLimit
+	buf  []byte // 未读完的帧头或 SETTINGS 帧
+	skip int    // 当前帧还需跳过的负载长度
+	done bool
+}
+
+func (c *sniffConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 && !c.done {
+		c.sniff(b[:n])
+	}
+	return n, err
+}
+
+func (c *sniffConn) sniff(b []byte) {
+	for len(b) > 0 {
+		if c.skip > 0 {
+			n := c.skip
+			if n > len(b) {
+				n = len(b)
+			}
+			c.skip -= n
+			b = b[n:]
+			continue
+		}
+
+		if len(c.buf) < http2FrameHeaderLen {
+			b = c.fill(b, http2FrameHeaderLen)
+			if len(c.buf) < http2FrameHeaderLen {
+				return
+			}
+		}
+		length := int(c.buf[0])<<16 | int(c.buf[1])<<8 | int(c.buf[2])
+		if typ, flags := c.buf[3], c.buf[4]; typ != http2FrameSettings || flags&http2FlagSettingsAck != 0 {
+			c.buf = c.buf[:0]
+			c.skip = length
+			continue
+		}
+		if length > maxSettingsLen {
+			c.stop()
+			return
+		}
+
+		b = c.fill(b, http2FrameHeaderLen+length)
+		if len(c.buf) < http2FrameHeaderLen+length {
+			return
+		}
+		payload := c.buf[http2FrameHeaderLen:]
+		for i := 0; i+6 <= len(payload); i += 6 {
+			if binary.BigEndian.Uint16(payload[i:]) == http2SettingMaxConcurrentStrs {
+				c.l.set(binary.BigEndian.Uint32(payload[i+2:]))
+			}
+		}
+		c.buf = c.buf[:0]
+	}
+}
+
+// fill 从 b 中读取数据直到 c.buf 长度达到 n，返回剩余的数据
+func (c *sniffConn) fill(b []byte, n int) []byte {
+	n -= len(c.buf)
+	if n > len(b) {
+		n = len(b)
+	}
+	c.buf = append(c.buf, b[:n]...)
+	return b[n:]
+}
+
+func (c *sniffConn) stop() {
+	c.done = true
+	c.buf = nil
+}
@@ -0,0 +1,63 @@
+package grpcpool_test
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/hunyxv/grpcpool"
+	"github.com/hunyxv/grpcpool/grpcpooltest"
+	"google.golang.org/grpc"
+)
+
+func limits(p *grpcpool.Pool) map[int32]int {
+	m := make(map[int32]int)
+	for _, cs := range p.Stats().Conns {
+		m[cs.ID] = cs.Limit
+	}
+	return m
+}
+
+func TestStreamLimitPerConn(t *testing.T) {
+	low := grpcpooltest.NewServer(grpcpooltest.WithMaxConcurrentStreams(3))
+	defer low.Close()
+	high := grpcpooltest.NewServer(grpcpooltest.WithMaxConcurrentStreams(5))
+	defer high.Close()
+
+	// 两个连接分别拨向流数限制不同的服务端
+	d := grpcpool.NewStreamLimitDetector()
+	servers := []*grpcpooltest.Server{low, high}
+	var dials int
+	p, err := grpcpool.NewPool(func() (*grpc.ClientConn, error) {
+		s := servers[dials%2]
+		dials++
+		return s.Dial(d.ContextDialer(s.DialContext))
+	},
+		grpcpool.WithGrpcPoolSize(2),
+		grpcpool.WithMaxIdle(2),
+		grpcpool.WithMaxStreamsClient(100),
+		grpcpool.WithCleanIntervalTime(time.Hour),
+		grpcpool.WithStreamLimitDiscovery(d, 0),
+		grpcpool.WithLogger(log.New(ioutil.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	conns := p.Stats().Conns
+	lowID, highID := conns[0].ID, conns[1].ID
+	waitFor(t, func() bool {
+		l := limits(p)
+		return l[lowID] == 3 && l[highID] == 5
+	})
+
+	// 重连后的新 SETTINGS 立即生效，不等待清理
+	low.SetMaxConcurrentStreams(2)
+	waitFor(t, func() bool {
+		return limits(p)[lowID] == 2
+	})
+	if n := limits(p)[highID]; n != 5 {
+		t.Fatalf("limit of the other conn changed to %d", n)
+	}
+}
@@ -0,0 +1,154 @@
+package grpcpool
+
+import (
+	"context"
+	"math"
+	"sort"
+)
+
+// Tenant configures the share of the pool capacity of a tenant, see
+// WithTenants.
+type Tenant struct {
+	// Name is matched against NewTenantContext. The Tenant with an empty Name
+	// applies to untagged Gets and to unknown tenants.
+	Name string
+
+	// Guaranteed is the share of the capacity the tenant is entitled to. With
+	// ShedQueue, the others can borrow it while the tenant uses less, and the
+	// borrowed leases are given back to the tenant first as they are
+	// returned. With ShedReject and ShedPassthrough, which can't take leases
+	// back, the unused share is kept for the tenant.
+	Guaranteed float64
+
+	// Max is the share of the capacity the tenant can use at most, borrowing
+	// included. 0 means the whole capacity.
+	Max float64
+}
+
+// TenantUsage reports the usage of a tenant, see Stats.
+type TenantUsage struct {
+	Name string
+
+	// Guaranteed and Max are the quotas of the tenant in leases
+	Guaranteed int
+	Max        int
+
+	InFlight int
+	// Borrowed is the number of leases over Guaranteed
+	Borrowed int
+	// Rejected counts the Gets shed for the tenant
+	Rejected uint64
+}
+
+// tenantState 租户的配额以及使用情况，由 admission.mux 保护
+type tenantState struct {
+	name       string
+	guaranteed int
+	max        int
+	inflight   int
+	rejected   uint64
+}
+
+// underGuarantee 租户是否未用满保证名额，未配置租户时为 false
+func (t *tenantState) underGuarantee() bool {
+	return t != nil && t.inflight < t.guaranteed
+}
+
+func (t *tenantState) usage() TenantUsage {
+	u := TenantUsage{
+		Name:       t.name,
+		Guaranteed: t.guaranteed,
+		Max:        t.max,
+		InFlight:   t.inflight,
+		Rejected:   t.rejected,
+	}
+	if t.inflight > t.guaranteed {
+		u.Borrowed = t.inflight - t.guaranteed
+	}
+	return u
+}
+
+type tenantKey struct{}
+
+// NewTenantContext returns a copy of ctx tagged with the tenant name, which
+// is used by GetContext, Do and Invoke to admit the lease against the quota
+// of the tenant.
+func NewTenantContext(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, name)
+}
+
+// TenantFromContext returns the tenant ctx is tagged with, "" if none.
+func TenantFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(tenantKey{}).(string)
+	return name
+}
+
+// validTenants 检查租户配额，保证份额之和不能超过 1
+func validTenants(tenants []Tenant) bool {
+	var guaranteed float64
+	names := make(map[string]bool, len(tenants))
+	for _, t := range tenants {
+		max := t.Max
+		if max == 0 {
+			max = 1
+		}
+		if names[t.Name] || t.Guaranteed < 0 || t.Guaranteed > max || max > 1 {
+			return false
+		}
+		names[t.Name] = true
+		guaranteed += t.Guaranteed
+	}
+	return guaranteed <= 1
+}
+
+// setTenants 按容量换算租户的名额，未配置空名称的租户时其保证份额为 0。
+// 容量变化时再次调用，保留各租户的使用情况
+func (a *admission) setTenants(tenants []Tenant) {
+	a.quotas = tenants
+	if a.tenants == nil {
+		a.tenants = make(map[string]*tenantState, len(tenants)+1)
+		a.tenants[""] = new(tenantState)
+	}
+	a.tenants[""].max = a.limit
+	for _, t := range tenants {
+		ts, ok := a.tenants[t.Name]
+		if !ok {
+			ts = &tenantState{name: t.Name}
+			a.tenants[t.Name] = ts
+		}
+		ts.guaranteed = int(t.Guaranteed * float64(a.limit))
+		ts.max = a.limit
+		if t.Max > 0 {
+			ts.max = int(math.Ceil(t.Max * float64(a.limit)))
+		}
+	}
+}
+
+// tenant 返回名为 name 的租户，未知的租户按空名称的租户处理，调用方需持有 a.mux
+func (a *admission) tenant(name string) *tenantState {
+	if a.tenants == nil {
+		return nil
+	}
+	if t, ok := a.tenants[name]; ok {
+		return t
+	}
+	return a.tenants[""]
+}
+
+// usage 返回各租户的使用情况，按名称排序
+func (a *admission) usage() []TenantUsage {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	if a.tenants == nil {
+		return nil
+	}
+	usage := make([]TenantUsage, 0, len(a.tenants))
+	for _, t := range a.tenants {
+		usage = append(usage, t.usage())
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].Name < usage[j].Name
+	})
+	return usage
+}
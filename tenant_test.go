@@ -0,0 +1,153 @@
+package grpcpool_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hunyxv/grpcpool"
+	"github.com/hunyxv/grpcpool/grpcpooltest"
+)
+
+func usage(p *grpcpool.Pool, name string) grpcpool.TenantUsage {
+	for _, u := range p.Stats().Tenants {
+		if u.Name == name {
+			return u
+		}
+	}
+	return grpcpool.TenantUsage{}
+}
+
+func TestTenantQuotas(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	p := newPool(t, s,
+		grpcpool.WithMaxInFlight(4, grpcpool.ShedPolicy{Mode: grpcpool.ShedReject}),
+		grpcpool.WithTenants(
+			grpcpool.Tenant{Name: "a", Guaranteed: 0.5, Max: 0.5},
+			grpcpool.Tenant{Name: "b", Guaranteed: 0.25},
+		))
+	defer p.Close()
+
+	a := grpcpool.NewTenantContext(context.Background(), "a")
+	b := grpcpool.NewTenantContext(context.Background(), "b")
+	for i := 0; i < 2; i++ {
+		if _, err := p.GetContext(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oe := overloadError(t, func() error { _, err := p.GetContext(a); return err }())
+	if oe.Tenant != "a" {
+		t.Fatalf("tenant = %q, want a", oe.Tenant)
+	}
+
+	// b 借用未被保证的名额
+	for i := 0; i < 2; i++ {
+		if _, err := p.GetContext(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	overloadError(t, func() error { _, err := p.Get(); return err }())
+
+	if u := usage(p, "a"); u.InFlight != 2 || u.Max != 2 || u.Rejected != 1 {
+		t.Fatalf("unexpected usage of a: %+v", u)
+	}
+	if u := usage(p, "b"); u.InFlight != 2 || u.Guaranteed != 1 || u.Borrowed != 1 {
+		t.Fatalf("unexpected usage of b: %+v", u)
+	}
+	if u := usage(p, ""); u.Rejected != 1 {
+		t.Fatalf("unexpected usage of untagged leases: %+v", u)
+	}
+}
+
+func TestTenantReclaimsGuarantee(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	p := newPool(t, s,
+		grpcpool.WithMaxInFlight(2, grpcpool.ShedPolicy{Mode: grpcpool.ShedQueue}),
+		grpcpool.WithTenants(
+			grpcpool.Tenant{Name: "a", Guaranteed: 0.5},
+			grpcpool.Tenant{Name: "b", Guaranteed: 0.5},
+		))
+	defer p.Close()
+
+	a := grpcpool.NewTenantContext(context.Background(), "a")
+	b := grpcpool.NewTenantContext(context.Background(), "b")
+	lc, err := p.GetContext(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.GetContext(b); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(chan string, 2)
+	// 关闭连接池时仍在排队的 Get 返回 ErrPoolClosed
+	get := func(ctx context.Context) {
+		if _, err := p.GetContext(ctx); err == nil {
+			got <- grpcpool.TenantFromContext(ctx)
+		}
+	}
+	go get(b)
+	waitFor(t, func() bool { return p.Stats().Queued == 1 })
+	go get(a)
+	waitFor(t, func() bool { return p.Stats().Queued == 2 })
+
+	// 归还的名额先还给未用满保证名额的 a
+	p.Put(lc)
+	if name := <-got; name != "a" {
+		t.Fatalf("lease returned to %q, want a", name)
+	}
+	if u := usage(p, "b"); u.InFlight != 1 || u.Borrowed != 0 {
+		t.Fatalf("unexpected usage of b: %+v", u)
+	}
+}
+
+func TestTenantGuaranteeWithoutQueue(t *testing.T) {
+	for _, mode := range []grpcpool.ShedMode{grpcpool.ShedReject, grpcpool.ShedPassthrough} {
+		t.Run(mode.String(), func(t *testing.T) {
+			s := grpcpooltest.NewServer()
+			defer s.Close()
+			p := newPool(t, s,
+				grpcpool.WithMaxInFlight(4, grpcpool.ShedPolicy{Mode: mode, Priority: grpcpool.PriorityHigh}),
+				grpcpool.WithTenants(grpcpool.Tenant{Name: "a", Guaranteed: 0.5}))
+			defer p.Close()
+
+			// 无法收回借出的名额，其他租户不能占用 a 未使用的保证名额
+			for i := 0; i < 2; i++ {
+				if _, err := p.Get(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			overloadError(t, func() error { _, err := p.Get(); return err }())
+
+			a := grpcpool.NewTenantContext(context.Background(), "a")
+			for i := 0; i < 2; i++ {
+				if _, err := p.GetContext(a); err != nil {
+					t.Fatal(err)
+				}
+			}
+			overloadError(t, func() error { _, err := p.GetContext(a); return err }())
+			if u := usage(p, "a"); u.InFlight != 2 || u.Rejected != 1 {
+				t.Fatalf("unexpected usage of a: %+v", u)
+			}
+		})
+	}
+}
+
+func TestInvalidTenants(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	_, err := grpcpool.NewPool(s.Builder(), grpcpool.WithTenants(
+		grpcpool.Tenant{Name: "a", Guaranteed: 0.6},
+		grpcpool.Tenant{Name: "b", Guaranteed: 0.6},
+	))
+	if err != grpcpool.ErrInvalidTenants {
+		t.Fatalf("err = %v, want ErrInvalidTenants", err)
+	}
+
+	// 没有固定的总容量时无法换算配额
+	_, err = grpcpool.NewPool(s.Builder(), grpcpool.WithTenants(grpcpool.Tenant{Name: "a", Guaranteed: 0.5}))
+	if err != grpcpool.ErrUnboundedCapacity {
+		t.Fatalf("err = %v, want %v", err, grpcpool.ErrUnboundedCapacity)
+	}
+}
@@ -0,0 +1,112 @@
+package grpcpool
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+)
+
+// Locality is the locality metadata of a grpcConn, see TargetBuilder.
+type Locality struct {
+	// Zone is compared with the zone set by WithLocalZone
+	Zone string
+	// Attributes are free-form metadata reported in Stats
+	Attributes map[string]string
+}
+
+// TargetBuilder creates a grpc.ClientConn together with its Locality, e.g.
+// by dialing the load balancer endpoint of one zone in turn.
+type TargetBuilder func() (*grpc.ClientConn, Locality, error)
+
+// NewTargetPool creates a pool whose grpcConns are built by tb and carry the
+// Locality it returns, see WithLocalZone.
+func NewTargetPool(tb TargetBuilder, opts ...Option) (*Pool, error) {
+	return newPool(tb, true, opts...)
+}
+
+// target 将 Builder 转换为没有 Locality 的 TargetBuilder
+func (b Builder) target() TargetBuilder {
+	return func() (*grpc.ClientConn, Locality, error) {
+		conn, err := b()
+		return conn, Locality{}, err
+	}
+}
+
+// pickLocal 优先从本地可用区获取逻辑连接，本地连接不健康或者使用率达到
+// 阈值时溢出到其他可用区，都没有可用连接时返回 nil。新建的本地连接还在
+// CONNECTING 时照常使用，调用会等待连接建立
+func (p *Pool) pickLocal(skip func(*grpcConn) bool) (LogicConn, int, error) {
+	zone, threshold := p.opt.LocalZone, p.opt.Spillover
+	local := func(gc *grpcConn) bool {
+		if skip != nil && skip(gc) || gc.locality.Zone != zone {
+			return true
+		}
+		if atomic.LoadInt32(&gc.suspect) == 1 || gc.isBroken() || gc.isFailing() {
+			return true
+		}
+		return float64(gc.inflight()+1) > threshold*float64(gc.capacity())
+	}
+	remote := func(gc *grpcConn) bool {
+		return skip != nil && skip(gc) || gc.locality.Zone == zone
+	}
+
+	if logicconn := p.pickAmong(local); logicconn != nil {
+		return logicconn, int(atomic.LoadInt32(&p.size)), nil
+	}
+	// 溢出到其他可用区，最后放宽到所有连接
+	logicconn, l, err := p.pickConn(remote)
+	if err != nil || logicconn != nil {
+		return logicconn, l, err
+	}
+	return p.pickConn(skip)
+}
+
+// pickAmong 在 skip 之外的连接中选取一个可用的 grpcConn，与 pick 一样
+// 按时间轮转起点，使租约分散到这些连接上
+func (p *Pool) pickAmong(skip func(*grpcConn) bool) LogicConn {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+
+	var buf [16]*grpcConn
+	conns := buf[:0]
+	for _, gc := range p.conns {
+		if !skip(gc) {
+			conns = append(conns, gc)
+		}
+	}
+	l := len(conns)
+	if l == 0 {
+		return nil
+	}
+	index := int(p.opt.Clock.Now().UnixNano() % int64(l))
+	for i := 0; i < l; i++ {
+		gc := conns[(index+i)%l]
+		if logicconn, err := gc.take(); err == nil {
+			return logicconn
+		}
+	}
+	return nil
+}
+
+// countZone 统计跨可用区的租约
+func (p *Pool) countZone(lc LogicConn) {
+	gc := lc.(*logicConn).gconn
+	if gc.locality.Zone == p.opt.LocalZone {
+		return
+	}
+	atomic.AddUint64(&p.crossZone, 1)
+	c, ok := p.zoneCounters.Load(gc.locality.Zone)
+	if !ok {
+		c, _ = p.zoneCounters.LoadOrStore(gc.locality.Zone, crossZoneCounter.WithLabelValues(p.name, gc.locality.Zone))
+	}
+	c.(prometheus.Counter).Inc()
+}
+
+// deleteZoneMetrics 连接池关闭后删除跨可用区租约的指标
+func (p *Pool) deleteZoneMetrics() {
+	p.zoneCounters.Range(func(zone, _ interface{}) bool {
+		crossZoneCounter.DeleteLabelValues(p.name, zone.(string))
+		return true
+	})
+}
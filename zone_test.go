@@ -0,0 +1,222 @@
+package grpcpool_test
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hunyxv/grpcpool"
+	"github.com/hunyxv/grpcpool/grpcpooltest"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// newZonePool 创建依次拨向可用区 a、b 的连接池
+func newZonePool(t *testing.T, s *grpcpooltest.Server, opts ...grpcpool.Option) *grpcpool.Pool {
+	zones := []string{"a", "b"}
+	var dials int
+	tb := func() (*grpc.ClientConn, grpcpool.Locality, error) {
+		zone := zones[dials%len(zones)]
+		dials++
+		conn, err := s.Dial()
+		return conn, grpcpool.Locality{Zone: zone, Attributes: map[string]string{"lb": zone + ".example"}}, err
+	}
+	opts = append([]grpcpool.Option{
+		grpcpool.WithLogger(log.New(ioutil.Discard, "", 0)),
+		grpcpool.WithMaxIdle(2),
+		grpcpool.WithGrpcPoolSize(2),
+		grpcpool.WithMaxStreamsClient(2),
+	}, opts...)
+	p, err := grpcpool.NewTargetPool(tb, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// zoneInFlight 返回各可用区的在途租约数
+func zoneInFlight(p *grpcpool.Pool) map[string]int {
+	m := make(map[string]int)
+	for _, cs := range p.Stats().Conns {
+		m[cs.Locality.Zone] += cs.InFlight
+	}
+	return m
+}
+
+func TestLocalZonePreferred(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	p := newZonePool(t, s, grpcpool.WithName("zone-preferred"), grpcpool.WithLocalZone("a", 1))
+	defer p.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, err := p.Get(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if m := zoneInFlight(p); m["a"] != 2 || m["b"] != 0 {
+		t.Fatalf("unexpected leases per zone: %v", m)
+	}
+
+	// 本地容量耗尽后溢出到其他可用区
+	if _, err := p.Get(); err != nil {
+		t.Fatal(err)
+	}
+	if m := zoneInFlight(p); m["b"] != 1 {
+		t.Fatalf("unexpected leases per zone: %v", m)
+	}
+	st := p.Stats()
+	if st.CrossZoneLeases != 1 {
+		t.Fatalf("%d cross-zone leases, want 1", st.CrossZoneLeases)
+	}
+	if lb := st.Conns[1].Locality.Attributes["lb"]; lb != "b.example" {
+		t.Fatalf("attributes not kept: %q", lb)
+	}
+
+	// 关闭后删除指标
+	if n := metricSeries(t, "grpcpool_cross_zone_leases", "zone-preferred"); n != 1 {
+		t.Fatalf("%d cross-zone series, want 1", n)
+	}
+	p.Close()
+	if n := metricSeries(t, "grpcpool_cross_zone_leases", "zone-preferred"); n != 0 {
+		t.Fatalf("%d cross-zone series after Close, want 0", n)
+	}
+}
+
+func TestLocalZoneSpread(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	clock := grpcpooltest.NewFakeClock(time.Now())
+	zones := []string{"a", "a", "b", "a"}
+	var dials int
+	tb := func() (*grpc.ClientConn, grpcpool.Locality, error) {
+		zone := zones[dials%len(zones)]
+		dials++
+		conn, err := s.Dial()
+		return conn, grpcpool.Locality{Zone: zone}, err
+	}
+	p, err := grpcpool.NewTargetPool(tb,
+		grpcpool.WithLogger(log.New(ioutil.Discard, "", 0)),
+		grpcpool.WithClock(clock),
+		grpcpool.WithMaxIdle(4),
+		grpcpool.WithGrpcPoolSize(4),
+		grpcpool.WithCleanIntervalTime(time.Hour),
+		grpcpool.WithLocalZone("a", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	// 与 pick 一样轮转起点，租约分散到各个本地连接
+	for i := 0; i < 6; i++ {
+		if _, err := p.Get(); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Nanosecond)
+	}
+	for _, cs := range p.Stats().Conns {
+		want := 2
+		if cs.Locality.Zone != "a" {
+			want = 0
+		}
+		if cs.InFlight != want {
+			t.Fatalf("conn %d in zone %s has %d leases, want %d", cs.ID, cs.Locality.Zone, cs.InFlight, want)
+		}
+	}
+}
+
+func TestLocalZoneConnecting(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	gate := make(chan struct{})
+	var dials int
+	tb := func() (*grpc.ClientConn, grpcpool.Locality, error) {
+		dials++
+		if dials > 1 {
+			conn, err := s.Dial()
+			return conn, grpcpool.Locality{Zone: "b"}, err
+		}
+		// 本地连接在 gate 关闭前一直在建立连接
+		conn, err := grpc.Dial("passthrough:///bufconn", grpc.WithInsecure(),
+			grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+				select {
+				case <-gate:
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+				return s.DialContext(ctx, addr)
+			}))
+		return conn, grpcpool.Locality{Zone: "a"}, err
+	}
+	p, err := grpcpool.NewTargetPool(tb,
+		grpcpool.WithLogger(log.New(ioutil.Discard, "", 0)),
+		grpcpool.WithMaxIdle(2),
+		grpcpool.WithGrpcPoolSize(2),
+		grpcpool.WithLocalZone("a", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	// 还没有 READY 的本地连接不会导致溢出
+	lc, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := zoneInFlight(p); m["a"] != 1 || p.Stats().CrossZoneLeases != 0 {
+		t.Fatalf("unexpected leases per zone: %v", m)
+	}
+	close(gate)
+	if err := lc.Conn().Invoke(context.Background(), grpcpooltest.EchoMethod, new(emptypb.Empty), new(emptypb.Empty)); err != nil {
+		t.Fatal(err)
+	}
+	p.Put(lc)
+}
+
+func TestLocalZoneOptions(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+
+	if _, err := grpcpool.NewPool(s.Builder(), grpcpool.WithLocalZone("a", 1)); err != grpcpool.ErrNoLocality {
+		t.Fatalf("err = %v, want %v", err, grpcpool.ErrNoLocality)
+	}
+	tb := func() (*grpc.ClientConn, grpcpool.Locality, error) {
+		conn, err := s.Dial()
+		return conn, grpcpool.Locality{Zone: "a"}, err
+	}
+	for _, spillover := range []float64{0, -0.5, 1.5} {
+		if _, err := grpcpool.NewTargetPool(tb, grpcpool.WithLocalZone("a", spillover)); err != grpcpool.ErrInvalidSpillover {
+			t.Fatalf("spillover %g: err = %v, want %v", spillover, err, grpcpool.ErrInvalidSpillover)
+		}
+	}
+}
+
+func TestLocalZoneSpillover(t *testing.T) {
+	s := grpcpooltest.NewServer()
+	defer s.Close()
+	p := newZonePool(t, s, grpcpool.WithLocalZone("a", 0.5))
+	defer p.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, err := p.Get(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if m := zoneInFlight(p); m["a"] != 1 || m["b"] != 1 {
+		t.Fatalf("unexpected leases per zone: %v", m)
+	}
+
+	// 本地连接排空后不再优先使用
+	if err := p.Drain(p.Stats().Conns[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Get(); err != nil {
+		t.Fatal(err)
+	}
+	if m := zoneInFlight(p); m["b"] != 2 || p.Stats().CrossZoneLeases != 2 {
+		t.Fatalf("unexpected leases per zone: %v", m)
+	}
+}